@@ -4,25 +4,48 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	APPPort          string
-	DBHost           string
-	DBPort           string
-	DBName           string
-	DBUser           string
-	DBPassword       string
-	DBSSL            string
-	RedisHost        string
-	RedisPort        string
-	RedisPassword    string
-	RedisDB          int
-	JWTSecret        string
-	JWTAccessExpiry  int
-	JWTRefreshExpiry int
+	APPPort           string
+	DBHost            string
+	DBPort            string
+	DBName            string
+	DBUser            string
+	DBPassword        string
+	DBSSL             string
+	RedisHost         string
+	RedisPort         string
+	RedisPassword     string
+	RedisDB           int
+	JWTSecret         string
+	JWTAccessExpiry   int
+	JWTRefreshExpiry  int
+	SearchLanguage    string
+	PublishInterval   int
+	CommentMaxDepth   int
+	CommentApproval   bool
+	ReactionTypes     []string
+	FeedSize          int
+	FeedTTL           int
+	RevisionLimit     int
+	StorageDriver     string
+	StoragePath       string
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKey       string
+	S3SecretKey       string
+	S3PathStyle       bool
+	UploadMaxSize     int
+	UploadQuota       int
+	UploadOrphanTTL   int
+	ThumbnailSize     int
+	ViewWindow        int
+	ViewFlushInterval int
 }
 
 func LoadConfig() (*Config, error) {
@@ -31,22 +54,54 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("error loading .env file: %w", err)
 	}
 
-	return &Config{
-		APPPort:          getEnv("APP_PORT", "8080"),
-		DBHost:           getEnv("DB_HOST", "127.0.0.1"),
-		DBPort:           getEnv("DB_PORT", "5432"),
-		DBName:           getEnv("DB_NAME", "postgres"),
-		DBUser:           getEnv("DB_USER", "postgres"),
-		DBPassword:       getEnv("DB_PASSWORD", "postgres"),
-		DBSSL:            getEnv("DB_SSL", "disable"),
-		RedisHost:        getEnv("REDIS_HOST", "127.0.0.1"),
-		RedisPort:        getEnv("REDIS_PORT", "6379"),
-		RedisPassword:    getEnv("REDIS_PASSWORD", ""),
-		RedisDB:          getEnvAsInt("REDIS_DB", 0),
-		JWTSecret:        getEnv("JWT_SECRET", ""),
-		JWTAccessExpiry:  getEnvAsInt("JWT_ACCESS_EXPIRY", 3600),
-		JWTRefreshExpiry: getEnvAsInt("JWT_REFRESH_EXPIRY", 604800),
-	}, nil
+	cfg := &Config{
+		APPPort:           getEnv("APP_PORT", "8080"),
+		DBHost:            getEnv("DB_HOST", "127.0.0.1"),
+		DBPort:            getEnv("DB_PORT", "5432"),
+		DBName:            getEnv("DB_NAME", "postgres"),
+		DBUser:            getEnv("DB_USER", "postgres"),
+		DBPassword:        getEnv("DB_PASSWORD", "postgres"),
+		DBSSL:             getEnv("DB_SSL", "disable"),
+		RedisHost:         getEnv("REDIS_HOST", "127.0.0.1"),
+		RedisPort:         getEnv("REDIS_PORT", "6379"),
+		RedisPassword:     getEnv("REDIS_PASSWORD", ""),
+		RedisDB:           getEnvAsInt("REDIS_DB", 0),
+		JWTSecret:         getEnv("JWT_SECRET", ""),
+		JWTAccessExpiry:   getEnvAsInt("JWT_ACCESS_EXPIRY", 3600),
+		JWTRefreshExpiry:  getEnvAsInt("JWT_REFRESH_EXPIRY", 604800),
+		SearchLanguage:    getEnv("SEARCH_LANGUAGE", "english"),
+		PublishInterval:   getEnvAsInt("PUBLISH_INTERVAL", 60),
+		CommentMaxDepth:   getEnvAsInt("COMMENT_MAX_DEPTH", 5),
+		CommentApproval:   getEnvAsBool("COMMENT_REQUIRE_APPROVAL", false),
+		ReactionTypes:     append([]string{"like"}, getEnvAsList("REACTION_TYPES", "love,haha,wow,sad,angry")...),
+		FeedSize:          getEnvAsInt("FEED_SIZE", 500),
+		FeedTTL:           getEnvAsInt("FEED_TTL", 3600),
+		RevisionLimit:     getEnvAsInt("POST_REVISION_LIMIT", 50),
+		StorageDriver:     getEnv("STORAGE_DRIVER", "local"),
+		StoragePath:       getEnv("STORAGE_PATH", "uploads"),
+		S3Endpoint:        getEnv("S3_ENDPOINT", "https://s3.amazonaws.com"),
+		S3Region:          getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:          getEnv("S3_BUCKET", ""),
+		S3AccessKey:       getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:       getEnv("S3_SECRET_KEY", ""),
+		S3PathStyle:       getEnvAsBool("S3_PATH_STYLE", false),
+		UploadMaxSize:     getEnvAsInt("UPLOAD_MAX_SIZE", 10<<20),
+		UploadQuota:       getEnvAsInt("UPLOAD_QUOTA", 100<<20),
+		UploadOrphanTTL:   getEnvAsInt("UPLOAD_ORPHAN_TTL", 86400),
+		ThumbnailSize:     getEnvAsInt("THUMBNAIL_SIZE", 320),
+		ViewWindow:        getEnvAsInt("VIEW_WINDOW", 1800),
+		ViewFlushInterval: getEnvAsInt("VIEW_FLUSH_INTERVAL", 60),
+	}
+
+	// the background jobs tick at these intervals
+	if cfg.PublishInterval <= 0 {
+		return nil, fmt.Errorf("PUBLISH_INTERVAL must be positive, got %d", cfg.PublishInterval)
+	}
+	if cfg.ViewFlushInterval <= 0 {
+		return nil, fmt.Errorf("VIEW_FLUSH_INTERVAL must be positive, got %d", cfg.ViewFlushInterval)
+	}
+
+	return cfg, nil
 }
 
 func getEnv(key, defaultValue string) string {
@@ -64,3 +119,22 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsList(key, defaultValue string) []string {
+	list := []string{}
+	for _, value := range strings.Split(getEnv(key, defaultValue), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
+		}
+	}
+	return list
+}
@@ -0,0 +1,387 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gin-rest-api/config"
+	"gin-rest-api/models"
+	"gin-rest-api/storage"
+	"gin-rest-api/utils"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+var attachmentFields = utils.Resource{
+	Fields: map[string]utils.Field{
+		"id":           {Column: "id", Key: "id", Type: utils.FieldInt},
+		"user_id":      {Column: "user_id", Key: "user_id", Type: utils.FieldInt},
+		"post_id":      {Column: "post_id", Key: "post_id", Type: utils.FieldInt},
+		"name":         {Column: "name", Key: "name"},
+		"content_type": {Column: "content_type", Key: "content_type"},
+		"size":         {Column: "size", Key: "size", Type: utils.FieldInt},
+		"width":        {Column: "width", Key: "width", Type: utils.FieldInt},
+		"height":       {Column: "height", Key: "height", Type: utils.FieldInt},
+		"created_at":   {Column: "created_at", Key: "created_at", Type: utils.FieldTime},
+	},
+	Filters:     []string{"post_id", "content_type", "size", "created_at"},
+	Sorts:       []string{"name", "size", "created_at"},
+	DefaultSort: "-created_at",
+}
+
+// uploadTypes are the sniffed content types that may be uploaded, with the
+// extension their files are stored under.
+var uploadTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"application/zip": ".zip",
+	"text/plain":      ".txt",
+}
+
+// thumbnailTypes are the image types that get a thumbnail.
+var thumbnailTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+var errQuotaExceeded = errors.New("upload quota exceeded")
+
+type AttachmentAPI struct {
+	cfg         *config.Config
+	db          *gorm.DB
+	store       storage.Storage
+	attachments *utils.Repository[models.Attachment]
+}
+
+func NewAttachmentAPI(cfg *config.Config, db *gorm.DB, store storage.Storage) *AttachmentAPI {
+	return &AttachmentAPI{cfg, db, store, utils.NewRepository[models.Attachment](cfg, db)}
+}
+
+// Upload stores the multipart `file` of the request, optionally linked to a
+// post of the current user with `postId`. The type of the file is sniffed
+// from its content, whatever name and type the client gave.
+func (a *AttachmentAPI) Upload(c *gin.Context) {
+	maxSize := int64(a.cfg.UploadMaxSize)
+
+	// leaves room for the multipart framing and the other fields
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+
+	var req models.AttachmentRequest
+
+	if err := c.ShouldBindWith(&req, binding.FormMultipart); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		a.uploadError(c, err)
+		return
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		a.uploadError(c, err)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxSize {
+		utils.StatusTooLarge(c, fmt.Sprintf("the file must be at most %d bytes", maxSize))
+		return
+	}
+
+	userId := utils.GetUserID(c)
+
+	if req.PostId != nil {
+		var post models.Post
+
+		if err := a.db.Select("id", "user_id").First(&post, *req.PostId).Error; err != nil {
+			utils.StatusNotFound(c, err, "the post not found")
+			return
+		}
+
+		if post.UserID != userId {
+			utils.StatusForbidden(c, "only the author can attach files to the post")
+			return
+		}
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		utils.StatusBadRequest(c, "the file cannot be read")
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+
+	ext, ok := uploadTypes[contentType]
+	if !ok {
+		utils.StatusUnprocessable(c, map[string]string{"file": "files of type " + contentType + " are not allowed"})
+		return
+	}
+
+	name, err := randomName()
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	attachment := models.Attachment{
+		UserID:      userId,
+		PostID:      req.PostId,
+		Key:         fmt.Sprintf("%d/%s%s", userId, name, ext),
+		Name:        header.Filename,
+		ContentType: contentType,
+		Size:        header.Size,
+	}
+
+	var thumbnail bytes.Buffer
+
+	if slices.Contains(thumbnailTypes, contentType) {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			utils.StatusServerError(c)
+			return
+		}
+
+		attachment.Width, attachment.Height, err = utils.Thumbnail(&thumbnail, file, a.cfg.ThumbnailSize)
+		if err != nil {
+			msg := "the image cannot be read"
+			if errors.Is(err, utils.ErrImageTooLarge) {
+				msg = err.Error()
+			}
+			utils.StatusUnprocessable(c, map[string]string{"file": msg})
+			return
+		}
+
+		attachment.ThumbnailKey = fmt.Sprintf("%d/%s_thumb%s", userId, name, uploadTypes[utils.ThumbnailType(contentType)])
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	err = a.db.Transaction(func(tx *gorm.DB) error {
+		// serializes the uploads of a user so that they cannot overrun the quota together
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('attachments.quota'), ?)", userId).Error; err != nil {
+			return err
+		}
+
+		var used int64
+
+		err := tx.Model(&models.Attachment{}).Where("user_id = ?", userId).
+			Select("COALESCE(SUM(size), 0)").Scan(&used).Error
+		if err != nil {
+			return err
+		}
+
+		if used+attachment.Size > int64(a.cfg.UploadQuota) {
+			return errQuotaExceeded
+		}
+
+		if err := a.store.Put(ctx, attachment.Key, file, attachment.Size, contentType); err != nil {
+			return err
+		}
+
+		if attachment.ThumbnailKey != "" {
+			err := a.store.Put(ctx, attachment.ThumbnailKey, &thumbnail, int64(thumbnail.Len()), utils.ThumbnailType(contentType))
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&attachment).Error
+	})
+	if err != nil {
+		if errors.Is(err, errQuotaExceeded) {
+			utils.StatusUnprocessable(c, map[string]string{
+				"file": fmt.Sprintf("the upload quota of %d bytes would be exceeded", a.cfg.UploadQuota),
+			})
+			return
+		}
+
+		storage.Remove(ctx, a.store, attachment.Keys()...)
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	utils.StatusOK(c, attachment, "the file has been uploaded")
+}
+
+func (a *AttachmentAPI) uploadError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		utils.StatusTooLarge(c, fmt.Sprintf("the file must be at most %d bytes", a.cfg.UploadMaxSize))
+		return
+	}
+
+	if errors.Is(err, http.ErrMissingFile) {
+		utils.StatusUnprocessable(c, map[string]string{"file": "the file is required"})
+		return
+	}
+
+	utils.StatusBadRequest(c, err.Error())
+}
+
+// Gets lists the current user's uploads.
+func (a *AttachmentAPI) Gets(c *gin.Context) {
+	a.gets(c, func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ?", utils.GetUserID(c))
+	})
+}
+
+// GetsPost lists the attachments of a post.
+func (a *AttachmentAPI) GetsPost(c *gin.Context) {
+	var post models.Post
+
+	if err := visiblePosts(c)(a.db).Select("id").First(&post, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	a.gets(c, func(db *gorm.DB) *gorm.DB {
+		return db.Where("post_id = ?", post.ID)
+	})
+}
+
+func (a *AttachmentAPI) gets(c *gin.Context, scope func(*gorm.DB) *gorm.DB) {
+	fields, err := utils.ParseFields(c, attachmentFields, "")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	params, err := utils.ParsePageParams(c, attachmentFields)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	result, err := a.attachments.Paginate(params, scope, fields.Scope)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			utils.StatusBadRequest(c, err.Error())
+			return
+		}
+
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	data, err := fields.Pick(result)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
+}
+
+func (a *AttachmentAPI) Get(c *gin.Context) {
+	attachment, err := a.attachments.First(c.Param("id"), a.readable(c))
+	if err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	utils.StatusOK(c, attachment)
+}
+
+// File streams the content of an attachment, or of its thumbnail with
+// `?thumbnail=true`. Images are shown inline, other files are downloaded.
+func (a *AttachmentAPI) File(c *gin.Context) {
+	attachment, err := a.attachments.First(c.Param("id"), a.readable(c))
+	if err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	key, contentType, size := attachment.Key, attachment.ContentType, attachment.Size
+
+	if c.Query("thumbnail") == "true" {
+		if attachment.ThumbnailKey == "" {
+			utils.StatusNotFound(c, gorm.ErrRecordNotFound, "the attachment has no thumbnail")
+			return
+		}
+
+		key, contentType, size = attachment.ThumbnailKey, utils.ThumbnailType(contentType), -1
+	}
+
+	reader, err := a.store.Open(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			utils.StatusNotFound(c, gorm.ErrRecordNotFound, "the file not found")
+			return
+		}
+
+		utils.StatusServerError(c)
+		return
+	}
+	defer reader.Close()
+
+	disposition := "attachment"
+	if strings.HasPrefix(contentType, "image/") {
+		disposition = "inline"
+	}
+
+	c.DataFromReader(http.StatusOK, size, contentType, reader, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Name}),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+func (a *AttachmentAPI) Delete(c *gin.Context) {
+	var attachment models.Attachment
+
+	if err := a.db.Where("user_id = ?", utils.GetUserID(c)).First(&attachment, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("avatar_id = ?", attachment.ID).Update("avatar_id", nil).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&attachment).Error
+	})
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	storage.Remove(c.Request.Context(), a.store, attachment.Keys()...)
+
+	utils.StatusOK(c, nil, "the attachment has been deleted")
+}
+
+// readable limits a query to the current user's uploads and the attachments
+// of the posts the user can see.
+func (a *AttachmentAPI) readable(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	posts := visiblePosts(c)(a.db.Model(&models.Post{}).Select("id"))
+
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ? OR post_id IN (?)", utils.GetUserID(c), posts)
+	}
+}
+
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"gin-rest-api/config"
+	"gin-rest-api/storage"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// testUpload posts a multipart file as user 7 and returns the response and
+// the files stored, by key.
+func testUpload(t *testing.T, cfg *config.Config, content []byte, expect func(sqlmock.Sqlmock)) (*httptest.ResponseRecorder, map[string][]byte) {
+	t.Helper()
+
+	db, mock := mockDB(t)
+
+	root := t.TempDir()
+	store, err := storage.NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expect != nil {
+		expect(mock)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "upload.bin")
+	part.Write(content)
+	form.Close()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/uploads", func(c *gin.Context) {
+		c.Set("userAuth", uint(7))
+		NewAttachmentAPI(cfg, db, store).Upload(c)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/uploads", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := map[string][]byte{}
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(root, path)
+			stored[filepath.ToSlash(rel)], _ = os.ReadFile(path)
+		}
+		return nil
+	})
+
+	return w, stored
+}
+
+func testPNG(t *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUpload(t *testing.T) {
+	cfg := &config.Config{UploadMaxSize: 1 << 20, UploadQuota: 10 << 20, ThumbnailSize: 32}
+
+	t.Run("thumbnail", func(t *testing.T) {
+		w, stored := testUpload(t, cfg, testPNG(t, 64, 16), func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(`SELECT COALESCE\(SUM\(size\), 0\) FROM "attachments" WHERE user_id = \$1`).
+				WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(0))
+			mock.ExpectQuery(`INSERT INTO "attachments"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			mock.ExpectCommit()
+		})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("got %d: %s", w.Code, w.Body)
+		}
+
+		var res struct {
+			Data struct {
+				ContentType   string `json:"content_type"`
+				Width, Height int
+			}
+		}
+		json.Unmarshal(w.Body.Bytes(), &res)
+		if res.Data.ContentType != "image/png" || res.Data.Width != 64 || res.Data.Height != 16 {
+			t.Fatalf("got %+v", res.Data)
+		}
+
+		if len(stored) != 2 {
+			t.Fatalf("got files %v", stored)
+		}
+		var thumbnail []byte
+		for key, content := range stored {
+			if !strings.HasPrefix(key, "7/") || !strings.HasSuffix(key, ".png") {
+				t.Fatalf("got key %s", key)
+			}
+			if strings.HasSuffix(key, "_thumb.png") {
+				thumbnail = content
+			}
+		}
+
+		config, err := png.DecodeConfig(bytes.NewReader(thumbnail))
+		if err != nil || config.Width != 32 || config.Height != 8 {
+			t.Fatalf("got a %dx%d thumbnail, %v", config.Width, config.Height, err)
+		}
+	})
+
+	t.Run("quota", func(t *testing.T) {
+		w, stored := testUpload(t, &config.Config{UploadMaxSize: 1 << 20, UploadQuota: 100}, []byte("some text"), func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(`SELECT COALESCE\(SUM\(size\), 0\)`).WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(95))
+			mock.ExpectRollback()
+		})
+
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "quota") {
+			t.Fatalf("got %d: %s", w.Code, w.Body)
+		}
+		if len(stored) != 0 {
+			t.Fatalf("got files %v", stored)
+		}
+	})
+
+	t.Run("type", func(t *testing.T) {
+		w, stored := testUpload(t, cfg, []byte("<html><script>alert(1)</script></html>"), nil)
+
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "text/html") {
+			t.Fatalf("got %d: %s", w.Code, w.Body)
+		}
+		if len(stored) != 0 {
+			t.Fatalf("got files %v", stored)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		w, _ := testUpload(t, &config.Config{UploadMaxSize: 8}, []byte("more than eight bytes"), nil)
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("got %d: %s", w.Code, w.Body)
+		}
+	})
+}
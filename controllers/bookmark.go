@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	"errors"
+	"gin-rest-api/config"
+	"gin-rest-api/models"
+	"gin-rest-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var bookmarkFields = utils.Resource{
+	Fields: map[string]utils.Field{
+		"id":            {Column: "id", Key: "id", Type: utils.FieldInt},
+		"post_id":       {Column: "post_id", Key: "post_id", Type: utils.FieldInt},
+		"collection_id": {Column: "collection_id", Key: "collection_id", Type: utils.FieldInt},
+		"created_at":    {Column: "created_at", Key: "created_at", Type: utils.FieldTime},
+	},
+	Filters:     []string{"collection_id", "created_at"},
+	Sorts:       []string{"created_at"},
+	DefaultSort: "-created_at",
+	Relations: map[string]utils.Relation{
+		"post": {
+			Name: "Post", Key: "post", LocalKey: "post_id", ForeignKey: "id",
+			Fields: map[string]utils.Field{
+				"id":         {Column: "id", Key: "ID"},
+				"title":      {Column: "title", Key: "title"},
+				"slug":       {Column: "slug", Key: "slug"},
+				"user_id":    {Column: "user_id", Key: "userID"},
+				"created_at": {Column: "created_at", Key: "CreatedAt"},
+			},
+		},
+		"collection": {
+			Name: "Collection", Key: "collection", LocalKey: "collection_id", ForeignKey: "id",
+			Fields: map[string]utils.Field{
+				"id":   {Column: "id", Key: "id"},
+				"name": {Column: "name", Key: "name"},
+			},
+		},
+	},
+}
+
+type BookmarkAPI struct {
+	cfg       *config.Config
+	db        *gorm.DB
+	bookmarks *utils.Repository[models.Bookmark]
+}
+
+func NewBookmarkAPI(cfg *config.Config, db *gorm.DB) *BookmarkAPI {
+	return &BookmarkAPI{cfg, db, utils.NewRepository[models.Bookmark](cfg, db)}
+}
+
+// Add bookmarks a post, or moves an existing bookmark to another collection.
+func (a *BookmarkAPI) Add(c *gin.Context) {
+	var req models.BookmarkRequest
+
+	if err := utils.ShouldBind(c, &req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	var post models.Post
+
+	if err := visiblePosts(c)(a.db).Select("id").First(&post, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	userId := utils.GetUserID(c)
+
+	if req.CollectionId != nil {
+		err := a.db.Where("user_id = ?", userId).First(&models.BookmarkCollection{}, *req.CollectionId).Error
+		if err != nil {
+			utils.StatusNotFound(c, err, "the collection not found")
+			return
+		}
+	}
+
+	bookmark := models.Bookmark{
+		UserID:       userId,
+		PostID:       post.ID,
+		CollectionID: req.CollectionId,
+	}
+
+	result := a.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "post_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"collection_id"}),
+	}).Create(&bookmark)
+	if result.Error != nil {
+		utils.StatusDBError(c, result.Error)
+		return
+	}
+
+	utils.StatusOK(c, bookmark, "the post has been bookmarked")
+}
+
+func (a *BookmarkAPI) Remove(c *gin.Context) {
+	result := a.db.Where("user_id = ? AND post_id = ?", utils.GetUserID(c), c.Param("id")).Delete(&models.Bookmark{})
+	if result.Error != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		utils.StatusNotFound(c, gorm.ErrRecordNotFound, "the bookmark not found")
+		return
+	}
+
+	utils.StatusOK(c, nil, "the bookmark has been removed")
+}
+
+// Gets lists the current user's bookmarks, newest first. Bookmarks of posts
+// that are trashed or no longer visible are left out.
+func (a *BookmarkAPI) Gets(c *gin.Context) {
+	fields, err := utils.ParseFields(c, bookmarkFields, "post,collection")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	params, err := utils.ParsePageParams(c, bookmarkFields)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	posts := visiblePosts(c)(a.db.Model(&models.Post{}).Select("id"))
+
+	result, err := a.bookmarks.Paginate(params, func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ? AND post_id IN (?)", utils.GetUserID(c), posts)
+	}, fields.Scope)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			utils.StatusBadRequest(c, err.Error())
+			return
+		}
+
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	data, err := fields.Pick(result)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
+}
+
+func (a *BookmarkAPI) Collections(c *gin.Context) {
+	collections := []models.BookmarkCollection{}
+
+	if err := a.db.Where("user_id = ?", utils.GetUserID(c)).Order("name").Find(&collections).Error; err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, collections)
+}
+
+func (a *BookmarkAPI) CreateCollection(c *gin.Context) {
+	var req models.BookmarkCollectionRequest
+
+	if err := utils.ShouldBind(c, &req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	collection := models.BookmarkCollection{
+		UserID: utils.GetUserID(c),
+		Name:   req.Name,
+	}
+
+	if err := a.db.Create(&collection).Error; err != nil {
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	utils.StatusOK(c, collection)
+}
+
+func (a *BookmarkAPI) UpdateCollection(c *gin.Context) {
+	var req models.BookmarkCollectionRequest
+
+	if err := utils.ShouldBind(c, &req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	var collection models.BookmarkCollection
+
+	if err := a.db.Where("user_id = ?", utils.GetUserID(c)).First(&collection, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	if err := a.db.Model(&collection).Update("name", req.Name).Error; err != nil {
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	utils.StatusOK(c, collection)
+}
+
+// DeleteCollection deletes a collection. Its bookmarks are kept without a
+// collection.
+func (a *BookmarkAPI) DeleteCollection(c *gin.Context) {
+	var collection models.BookmarkCollection
+
+	if err := a.db.Where("user_id = ?", utils.GetUserID(c)).First(&collection, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Bookmark{}).Where("collection_id = ?", collection.ID).Update("collection_id", nil).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(&collection).Error
+	})
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, nil, "the collection has been deleted successfully")
+}
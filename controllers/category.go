@@ -1,30 +1,83 @@
 package controllers
 
 import (
+	"errors"
+	"gin-rest-api/config"
 	"gin-rest-api/models"
 	"gin-rest-api/utils"
-	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
-	"github.com/gosimple/slug"
 	"gorm.io/gorm"
 )
 
+var categoryFields = utils.Resource{
+	Fields: map[string]utils.Field{
+		"id":         {Column: "id", Key: "ID", Type: utils.FieldInt},
+		"name":       {Column: "name", Key: "name"},
+		"slug":       {Column: "slug", Key: "slug"},
+		"path":       {Column: "path", Key: "path"},
+		"parent_id":  {Column: "parent_id", Key: "parent_id", Type: utils.FieldInt},
+		"created_at": {Column: "created_at", Key: "CreatedAt", Type: utils.FieldTime},
+		"updated_at": {Column: "updated_at", Key: "UpdatedAt", Type: utils.FieldTime},
+		"deleted_at": {Column: "deleted_at", Key: "DeletedAt", Type: utils.FieldTime},
+	},
+	Filters: []string{"id", "name", "slug", "path", "parent_id", "created_at", "updated_at", "deleted_at"},
+	Sorts:   []string{"id", "name", "slug", "path", "created_at", "updated_at", "deleted_at"},
+	Relations: map[string]utils.Relation{
+		"parent": {
+			Name: "Parent", Key: "Parent", LocalKey: "parent_id", ForeignKey: "id",
+			Fields: map[string]utils.Field{
+				"id":   {Column: "id", Key: "ID"},
+				"name": {Column: "name", Key: "name"},
+				"slug": {Column: "slug", Key: "slug"},
+				"path": {Column: "path", Key: "path"},
+			},
+		},
+		"children": {
+			Name: "Children", Key: "Children", LocalKey: "id", ForeignKey: "parent_id",
+			Fields: map[string]utils.Field{
+				"id":   {Column: "id", Key: "ID"},
+				"name": {Column: "name", Key: "name"},
+				"slug": {Column: "slug", Key: "slug"},
+				"path": {Column: "path", Key: "path"},
+			},
+		},
+		"posts": {
+			Name: "Posts", Key: "Posts", LocalKey: "id", ForeignKey: "category_id",
+			Scope: visiblePosts,
+			Fields: map[string]utils.Field{
+				"id":          {Column: "id", Key: "ID"},
+				"title":       {Column: "title", Key: "title"},
+				"slug":        {Column: "slug", Key: "slug"},
+				"category_id": {Column: "category_id", Key: "categoryID"},
+				"user_id":     {Column: "user_id", Key: "userID"},
+				"created_at":  {Column: "created_at", Key: "CreatedAt"},
+			},
+		},
+	},
+}
+
 type CategoryAPI struct {
-	db *gorm.DB
+	cfg        *config.Config
+	db         *gorm.DB
+	categories *utils.Repository[models.Category]
 }
 
-func NewCategoryAPI(db *gorm.DB) *CategoryAPI {
-	return &CategoryAPI{db}
+func NewCategoryAPI(cfg *config.Config, db *gorm.DB) *CategoryAPI {
+	return &CategoryAPI{cfg, db, utils.NewRepository[models.Category](cfg, db)}
 }
 
+var errCategoryCycle = errors.New("a category cannot be moved under itself or its subcategories")
+
 func (a *CategoryAPI) Create(c *gin.Context) {
-	var req models.CategoryRequest
+	var req models.CategoryAddRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := utils.ShouldBind(c, &req); err != nil {
 		if errs, ok := err.(validator.ValidationErrors); ok {
-			utils.StatusUnprocessable(c, utils.FormatErrors(errs))
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
 			return
 		}
 
@@ -32,21 +85,20 @@ func (a *CategoryAPI) Create(c *gin.Context) {
 		return
 	}
 
-	if utils.IsExist(a.db, "categories", "name", req.Name) ||
-		utils.IsExist(a.db, "categories", "slug", slug.Make(req.Name)) {
-		utils.StatusUnprocessable(c, map[string]any{
-			"Name": "the name is already exist!",
-		})
-		return
+	if req.ParentId != nil {
+		if err := a.db.First(&models.Category{}, *req.ParentId).Error; err != nil {
+			utils.StatusNotFound(c, err, "the parent category not found")
+			return
+		}
 	}
 
 	category := models.Category{
-		Name: req.Name,
+		Name:     req.Name,
+		ParentID: req.ParentId,
 	}
 
-	result := a.db.Create(&category)
-	if result.Error != nil {
-		utils.StatusServerError(c, "cannot create category")
+	if err := models.CreateWithSlug(a.db, &category); err != nil {
+		utils.StatusDBError(c, err, "cannot create category")
 		return
 	}
 
@@ -54,41 +106,188 @@ func (a *CategoryAPI) Create(c *gin.Context) {
 }
 
 func (a *CategoryAPI) Gets(c *gin.Context) {
-	var categories []models.Category
+	fields, err := utils.ParseFields(c, categoryFields, "")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
 
-	pageStr := c.DefaultQuery("page", "1")
-	page, _ := strconv.Atoi(pageStr)
+	params, err := utils.ParsePageParams(c, categoryFields)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	result, err := a.categories.Paginate(params, fields.Scope)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			utils.StatusBadRequest(c, err.Error())
+			return
+		}
 
-	perPageStr := c.DefaultQuery("perPage", "10")
-	perPage, _ := strconv.Atoi(perPageStr)
+		utils.StatusDBError(c, err)
+		return
+	}
 
-	result, err := utils.Paginate(a.db, page, perPage, nil, &categories)
+	data, err := fields.Pick(result)
 	if err != nil {
 		utils.StatusServerError(c)
 		return
 	}
 
-	utils.StatusOK(c, result)
+	utils.StatusOK(c, data)
 }
 
 func (a *CategoryAPI) Get(c *gin.Context) {
+	fields, err := utils.ParseFields(c, categoryFields, "")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	category, err := a.categories.First(c.Param("id"), fields.Scope)
+	if err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	data, err := fields.Pick(category)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
+}
+
+func (a *CategoryAPI) GetBySlug(c *gin.Context) {
+	fields, err := utils.ParseFields(c, categoryFields, "")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	category, err := a.categories.FirstBy("slug", c.Param("slug"), fields.Scope)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) && utils.RedirectSlug(c, a.db, "categories", c.Param("slug")) {
+			return
+		}
+
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	data, err := fields.Pick(category)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
+}
+
+func (a *CategoryAPI) GetByPath(c *gin.Context) {
+	fields, err := utils.ParseFields(c, categoryFields, "")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	category, err := a.categories.FirstBy("path", strings.Trim(c.Param("path"), "/"), fields.Scope)
+	if err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	data, err := fields.Pick(category)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
+}
+
+// Tree returns every category nested under its parent.
+func (a *CategoryAPI) Tree(c *gin.Context) {
+	var categories []models.Category
+
+	if err := a.db.Order("name").Find(&categories).Error; err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	nodes := make(map[uint]*models.CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &models.CategoryNode{
+			ID:       category.ID,
+			Name:     category.Name,
+			Slug:     category.Slug,
+			Path:     category.Path,
+			Children: []*models.CategoryNode{},
+		}
+	}
+
+	tree := []*models.CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID != nil {
+			if parent, ok := nodes[*category.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		tree = append(tree, node)
+	}
+
+	utils.StatusOK(c, tree)
+}
+
+// Breadcrumb returns the ancestors of a category from the root down to the
+// category itself.
+func (a *CategoryAPI) Breadcrumb(c *gin.Context) {
+	fields, err := utils.ParseFields(c, categoryFields, "")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
 	var category models.Category
 
-	result := a.db.First(&category, c.Param("id"))
-	if err := result.Error; err != nil {
+	if err := a.db.First(&category, c.Param("id")).Error; err != nil {
 		utils.StatusNotFound(c, err)
 		return
 	}
 
-	utils.StatusOK(c, category)
+	segments := strings.Split(category.Path, "/")
+	paths := make([]string, len(segments))
+	for i := range segments {
+		paths[i] = strings.Join(segments[:i+1], "/")
+	}
+
+	var crumbs []models.Category
+
+	err = fields.Scope(a.db).Where("path IN ?", paths).Order("length(path)").Find(&crumbs).Error
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	data, err := fields.Pick(crumbs)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
 }
 
 func (a *CategoryAPI) Update(c *gin.Context) {
 	var req models.CategoryRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := utils.ShouldBind(c, &req); err != nil {
 		if errs, ok := err.(validator.ValidationErrors); ok {
-			utils.StatusUnprocessable(c, utils.FormatErrors(errs))
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
 			return
 		}
 
@@ -104,30 +303,130 @@ func (a *CategoryAPI) Update(c *gin.Context) {
 		return
 	}
 
-	if (category.Name != req.Name &&
-		utils.IsExist(a.db, "categories", "name", req.Name)) ||
-		(category.Name != req.Name &&
-			utils.IsExist(a.db, "categories", "slug", slug.Make(req.Name))) {
-		utils.StatusUnprocessable(c, map[string]any{
-			"Name": "the name is already exist!",
-		})
-		return
-	}
-
 	updateCategory := models.Category{
 		Name: req.Name,
-		Slug: slug.Make(req.Name),
 	}
 
-	result = a.db.Model(&category).Updates(updateCategory)
-	if err := result.Error; err != nil {
-		utils.StatusServerError(c)
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		slug, err := models.RenameSlug(tx, "categories", category.ID, category.Slug, req.Name)
+		if err != nil {
+			return err
+		}
+		updateCategory.Slug = slug
+		updateCategory.Path = strings.TrimSuffix(category.Path, category.Slug) + slug
+
+		if err := tx.Model(&category).Updates(models.Category{Name: req.Name, Slug: slug}).Error; err != nil {
+			return err
+		}
+
+		return movePaths(tx, category.Path, updateCategory.Path)
+	})
+	if err != nil {
+		utils.StatusDBError(c, err)
 		return
 	}
 
 	utils.StatusOK(c, updateCategory)
 }
 
+// SetModeration turns comment approval on or off for the posts of a category.
+func (a *CategoryAPI) SetModeration(c *gin.Context) {
+	var req models.CategoryModerationRequest
+
+	if err := utils.ShouldBind(c, &req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	var category models.Category
+
+	if err := a.db.First(&category, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	if err := a.db.Model(&category).Update("require_comment_approval", *req.RequireApproval).Error; err != nil {
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	utils.StatusOK(c, category)
+}
+
+// Move puts a category and its subcategories under another parent, or at
+// the root when parentId is empty.
+func (a *CategoryAPI) Move(c *gin.Context) {
+	var req models.CategoryMoveRequest
+
+	if err := utils.ShouldBind(c, &req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	var category models.Category
+
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		// serializes moves so that two of them cannot build a cycle together
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('categories.path'))").Error; err != nil {
+			return err
+		}
+
+		if err := tx.First(&category, c.Param("id")).Error; err != nil {
+			return err
+		}
+
+		path := category.Slug
+		if req.ParentId != nil {
+			var parent models.Category
+
+			if err := tx.First(&parent, *req.ParentId).Error; err != nil {
+				return err
+			}
+			if strings.HasPrefix(parent.Path+"/", category.Path+"/") {
+				return errCategoryCycle
+			}
+
+			path = parent.Path + "/" + category.Slug
+		}
+
+		if err := tx.Model(&category).Update("parent_id", req.ParentId).Error; err != nil {
+			return err
+		}
+
+		if err := movePaths(tx, category.Path, path); err != nil {
+			return err
+		}
+
+		category.Path = path
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errCategoryCycle) {
+			utils.StatusUnprocessable(c, map[string]string{"parentId": err.Error()})
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.StatusNotFound(c, err)
+			return
+		}
+
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	utils.StatusOK(c, category, "the category has been moved successfully")
+}
+
 func (a *CategoryAPI) Delete(c *gin.Context) {
 	var category models.Category
 
@@ -137,35 +436,151 @@ func (a *CategoryAPI) Delete(c *gin.Context) {
 		return
 	}
 
-	a.db.Delete(&category)
+	var children int64
+
+	if err := a.db.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&children).Error; err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	if children > 0 {
+		utils.StatusConflict(c, "the category has subcategories, move or delete them first")
+		return
+	}
+
+	// the posts of the category are trashed at the same time so that they
+	// come back with the category
+	now := time.Now()
+
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Post{}).Where("category_id = ?", category.ID).UpdateColumn("deleted_at", now).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&category).UpdateColumn("deleted_at", now).Error
+	})
+	if err != nil {
+		utils.StatusDBError(c, err)
+		return
+	}
 
 	utils.StatusOK(c, nil, "the category has been deleted successfully")
 }
 
+// Restore takes a trashed category out of the trash with the posts that
+// were trashed with it. Its parent must not be in the trash.
+func (a *CategoryAPI) Restore(c *gin.Context) {
+	restoreOne(c, a.db, "category", a.restore)
+}
+
+// RestoreMany restores the trashed categories of `ids`.
+func (a *CategoryAPI) RestoreMany(c *gin.Context) {
+	restoreMany(c, a.db, "category", a.restore)
+}
+
+func (a *CategoryAPI) restore(tx *gorm.DB, id uint) (map[string]string, error) {
+	var category models.Category
+
+	if err := firstTrashed(tx, &category, id); err != nil {
+		return nil, err
+	}
+
+	conflicts := map[string]string{}
+
+	isTaken, err := taken(tx, &models.Category{}, "name", category.Name)
+	if err != nil {
+		return nil, err
+	}
+	if isTaken {
+		conflicts["name"] = "the name has been taken by another category"
+	}
+
+	if category.ParentID != nil {
+		live, err := taken(tx, &models.Category{}, "id", *category.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if !live {
+			conflicts["parent_id"] = "the parent category is in the trash"
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return conflicts, nil
+	}
+
+	if err := untrash(tx, &models.Post{}, "category_id = ? AND deleted_at = ?", category.ID, category.DeletedAt); err != nil {
+		return nil, err
+	}
+
+	return nil, untrash(tx, &models.Category{}, "id = ?", category.ID)
+}
+
 func (a *CategoryAPI) Trashed(c *gin.Context) {
-	var categories []models.Category
+	fields, err := utils.ParseFields(c, categoryFields, "")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
 
-	pageStr := c.DefaultQuery("page", "1")
-	page, _ := strconv.Atoi(pageStr)
+	params, err := utils.ParsePageParams(c, categoryFields)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	result, err := a.categories.Paginate(params, utils.Trashed, fields.Scope)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			utils.StatusBadRequest(c, err.Error())
+			return
+		}
 
-	perPageStr := c.DefaultQuery("perPage", "10")
-	perPage, _ := strconv.Atoi(perPageStr)
+		utils.StatusDBError(c, err)
+		return
+	}
 
-	result, err := utils.Paginate(a.db.Unscoped().Where("deleted_at IS NOT NULL"), page, perPage, nil, &categories)
+	data, err := fields.Pick(result)
 	if err != nil {
 		utils.StatusServerError(c)
 		return
 	}
 
-	utils.StatusOK(c, result)
+	utils.StatusOK(c, data)
 }
 
 func (a *CategoryAPI) EmptyTrash(c *gin.Context) {
-	result := a.db.Unscoped().Delete(&models.Category{}, c.Param("id"))
-	if err := result.Error; err != nil {
+	var category models.Category
+
+	if err := a.db.Unscoped().Where("deleted_at IS NOT NULL").First(&category, c.Param("id")).Error; err != nil {
 		utils.StatusNotFound(c, err)
 		return
 	}
 
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("target_type = ? AND target_id = ?", models.FollowCategories, category.ID).Delete(&models.Follow{}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&category).Error
+	})
+	if err != nil {
+		utils.StatusDBError(c, err)
+		return
+	}
+
 	utils.StatusOK(c, nil, "the category has been deleted permanently")
 }
+
+// movePaths rewrites the path of a category and of all its subcategories,
+// trashed ones included.
+func movePaths(tx *gorm.DB, from, to string) error {
+	if from == to {
+		return nil
+	}
+
+	// a prefix comparison, slugs may hold the _ wildcard of LIKE
+	return tx.Exec(`UPDATE categories SET path = ? || substr(path, ?) WHERE path = ? OR left(path, ?) = ?`,
+		to, len(from)+1, from, len(from)+1, from+"/").Error
+}
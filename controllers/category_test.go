@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMovePaths(t *testing.T) {
+	db, mock := mockDB(t)
+
+	// news_1/sports must not match newsx1/sports through the _ wildcard
+	mock.ExpectExec(`UPDATE categories SET path = \$1 \|\| substr\(path, \$2\) WHERE path = \$3 OR left\(path, \$4\) = \$5`).
+		WithArgs("world/news_1", 7, "news_1", 7, "news_1/").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	if err := movePaths(db, "news_1", "world/news_1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := movePaths(db, "same", "same"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
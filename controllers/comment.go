@@ -1,28 +1,72 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"gin-rest-api/config"
 	"gin-rest-api/models"
 	"gin-rest-api/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var commentFields = utils.Resource{
+	Fields: map[string]utils.Field{
+		"id":              {Column: "id", Key: "ID", Type: utils.FieldInt},
+		"post_id":         {Column: "post_id", Key: "postID", Type: utils.FieldInt},
+		"parent_id":       {Column: "parent_id", Key: "parentID", Type: utils.FieldInt},
+		"user_id":         {Column: "user_id", Key: "UserID", Type: utils.FieldInt},
+		"body":            {Column: "body", Key: "Body"},
+		"body_html":       {Column: "body_html", Key: "body_html"},
+		"body_text":       {Column: "body_text", Key: "body_text"},
+		"depth":           {Column: "depth", Key: "Depth"},
+		"path":            {Column: "path", Key: "Path"},
+		"reply_count":     {Column: "reply_count", Key: "ReplyCount"},
+		"deleted":         {Column: "deleted", Key: "Deleted"},
+		"status":          {Column: "status", Key: "Status"},
+		"reaction_counts": {Column: "reaction_counts", Key: "ReactionCounts"},
+		"created_at":      {Column: "created_at", Key: "CreatedAt", Type: utils.FieldTime},
+		"updated_at":      {Column: "updated_at", Key: "UpdatedAt", Type: utils.FieldTime},
+		"deleted_at":      {Column: "deleted_at", Key: "DeletedAt", Type: utils.FieldTime},
+	},
+	Filters:     []string{"post_id", "user_id", "status", "created_at", "deleted_at"},
+	Sorts:       []string{"created_at", "reply_count", "deleted_at"},
+	SortAliases: map[string]string{"oldest": "created_at", "newest": "-created_at"},
+	DefaultSort: "created_at",
+	Relations: map[string]utils.Relation{
+		"user": {
+			Name: "User", Key: "User", LocalKey: "user_id", ForeignKey: "id",
+			Fields: map[string]utils.Field{
+				"id":       {Column: "id", Key: "ID"},
+				"name":     {Column: "name", Key: "name"},
+				"username": {Column: "username", Key: "username"},
+			},
+		},
+	},
+}
+
+// commentOrder lists comments oldest first.
+var commentOrder = utils.SortBy(utils.Sort{Column: "created_at"})
+
 type CommentAPI struct {
-	db *gorm.DB
+	cfg      *config.Config
+	db       *gorm.DB
+	comments *utils.Repository[models.Comment]
 }
 
-func NewCommentAPI(db *gorm.DB) *CommentAPI {
-	return &CommentAPI{db}
+func NewCommentAPI(cfg *config.Config, db *gorm.DB) *CommentAPI {
+	return &CommentAPI{cfg, db, utils.NewRepository[models.Comment](cfg, db)}
 }
 
 func (a *CommentAPI) Create(c *gin.Context) {
 	var req models.CommentAddRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := utils.ShouldBind(c, &req); err != nil {
 		if errs, ok := err.(validator.ValidationErrors); ok {
-			utils.StatusUnprocessable(c, utils.FormatErrors(errs))
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
 			return
 		}
 
@@ -30,10 +74,16 @@ func (a *CommentAPI) Create(c *gin.Context) {
 		return
 	}
 
-	if !utils.IsExist(a.db, "posts", "id", req.PostId) {
-		utils.StatusUnprocessable(c, map[string]any{
-			"PostId": "the post does not exist",
-		})
+	var post models.Post
+
+	if err := visiblePosts(c)(a.db).Select("id").First(&post, req.PostId).Error; err != nil {
+		utils.StatusNotFound(c, err, "the post not found")
+		return
+	}
+
+	status, err := a.initialStatus(c, req.PostId)
+	if err != nil {
+		utils.StatusServerError(c)
 		return
 	}
 
@@ -41,35 +91,275 @@ func (a *CommentAPI) Create(c *gin.Context) {
 		UserID: utils.GetUserID(c),
 		PostID: req.PostId,
 		Body:   req.Body,
+		Status: status,
 	}
 
 	result := a.db.Create(&comment)
 	if result.Error != nil {
+		utils.StatusDBError(c, result.Error)
+		return
+	}
+
+	utils.StatusOK(c, comment)
+}
+
+func (a *CommentAPI) Reply(c *gin.Context) {
+	var req models.CommentReplyRequest
+
+	if err := utils.ShouldBind(c, &req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	var post models.Post
+
+	if err := visiblePosts(c)(a.db).Select("id").First(&post, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err, "the post not found")
+		return
+	}
+
+	var parent models.Comment
+
+	err := visibleComments(c, a.db)(a.db).Where("post_id = ?", post.ID).First(&parent, c.Param("comment_id")).Error
+	if err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	if parent.Deleted {
+		utils.StatusConflict(c, "the comment has been deleted")
+		return
+	}
+
+	if parent.Depth+1 > a.cfg.CommentMaxDepth {
+		utils.StatusUnprocessable(c, map[string]string{
+			"body": fmt.Sprintf("replies cannot be nested deeper than %d levels", a.cfg.CommentMaxDepth),
+		})
+		return
+	}
+
+	status, err := a.initialStatus(c, parent.PostID)
+	if err != nil {
 		utils.StatusServerError(c)
 		return
 	}
 
+	comment := models.Comment{
+		UserID:   utils.GetUserID(c),
+		PostID:   parent.PostID,
+		ParentID: &parent.ID,
+		Body:     req.Body,
+		Status:   status,
+		Depth:    parent.Depth + 1,
+		Path:     parent.Path + "/",
+	}
+
+	err = a.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&parent).UpdateColumn("reply_count", gorm.Expr("reply_count + 1")).Error
+	})
+	if err != nil {
+		utils.StatusDBError(c, err)
+		return
+	}
+
 	utils.StatusOK(c, comment)
 }
 
+// Thread returns the comments of a post, or a comment with its replies, as a
+// nested tree or, with `?format=flat`, as a list ordered depth first.
+// `?body=` picks the representation of the bodies as in the other lists.
+func (a *CommentAPI) Thread(c *gin.Context) {
+	format := c.DefaultQuery("format", "tree")
+	if format != "tree" && format != "flat" {
+		utils.StatusBadRequest(c, "format must be tree or flat")
+		return
+	}
+
+	bodyFormat, err := utils.ParseBodyFormat(c)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	var post models.Post
+
+	if err := visiblePosts(c)(a.db).Select("id").First(&post, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err, "the post not found")
+		return
+	}
+
+	visible := visibleComments(c, a.db)
+	query := visible(a.db).Where("post_id = ?", post.ID)
+
+	var root models.Comment
+
+	if id := c.Param("comment_id"); id != "" {
+		if err := visible(a.db).Where("post_id = ?", post.ID).First(&root, id).Error; err != nil {
+			utils.StatusNotFound(c, err)
+			return
+		}
+
+		query = query.Where("path = ? OR path LIKE ?", root.Path, root.Path+"/%")
+	}
+
+	var comments []models.Comment
+
+	err = commentsWithUser(query).Order("path").Find(&comments).Error
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	// comments are ordered by path, so parents come before their replies.
+	// Replies to hidden comments are hidden with them.
+	nodes := make(map[uint]*models.CommentNode, len(comments))
+	tree := []*models.CommentNode{}
+	flat := []models.Comment{}
+
+	for _, comment := range comments {
+		if comment.Deleted {
+			comment.User = models.User{}
+		}
+
+		node := &models.CommentNode{Comment: comment, Replies: []*models.CommentNode{}}
+
+		if comment.ParentID == nil || comment.ID == root.ID {
+			tree = append(tree, node)
+		} else if parent, ok := nodes[*comment.ParentID]; ok {
+			parent.Replies = append(parent.Replies, node)
+		} else {
+			continue
+		}
+
+		nodes[comment.ID] = node
+		flat = append(flat, comment)
+	}
+
+	var data any = tree
+	if format == "flat" {
+		data = flat
+	}
+
+	data, err = bodyFormat.Pick(data)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
+}
+
+// Gets lists the top-level comments of a post. Replies are read with Thread.
+func (a *CommentAPI) Gets(c *gin.Context) {
+	fields, err := utils.ParseFields(c, commentFields, "user")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	bodyFormat, err := utils.ParseBodyFormat(c)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	params, err := utils.ParsePageParams(c, commentFields)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	var post models.Post
+
+	if err := visiblePosts(c)(a.db).First(&post, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	result, err := a.comments.Paginate(params, topLevelComments(post.ID), visibleComments(c, a.db), fields.Scope)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			utils.StatusBadRequest(c, err.Error())
+			return
+		}
+
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	data, err := fields.Pick(result)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	data, err = bodyFormat.Pick(data)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
+}
+
 func (a *CommentAPI) Get(c *gin.Context) {
-	var comment models.Comment
+	fields, err := utils.ParseFields(c, commentFields, "")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
 
-	result := a.db.First(&comment, c.Param("comment_id"))
-	if err := result.Error; err != nil {
+	bodyFormat, err := utils.ParseBodyFormat(c)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	var post models.Post
+
+	if err := visiblePosts(c)(a.db).Select("id").First(&post, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err, "the post not found")
+		return
+	}
+
+	comment, err := a.comments.First(c.Param("comment_id"), func(db *gorm.DB) *gorm.DB {
+		return db.Where("post_id = ?", post.ID)
+	}, visibleComments(c, a.db), fields.Scope)
+	if err != nil {
 		utils.StatusNotFound(c, err)
 		return
 	}
 
-	utils.StatusOK(c, comment)
+	data, err := fields.Pick(comment)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	data, err = bodyFormat.Pick(data)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
 }
 
 func (a *CommentAPI) Update(c *gin.Context) {
 	var req models.CommentEditRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := utils.ShouldBind(c, &req); err != nil {
 		if errs, ok := err.(validator.ValidationErrors); ok {
-			utils.StatusUnprocessable(c, utils.FormatErrors(errs))
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
 			return
 		}
 
@@ -85,11 +375,20 @@ func (a *CommentAPI) Update(c *gin.Context) {
 		return
 	}
 
-	comment.Body = req.Body
+	if !a.mayChange(c, comment) {
+		utils.StatusForbidden(c, "only the author can edit the comment")
+		return
+	}
 
-	result = a.db.Save(&comment)
-	if result.Error != nil {
-		utils.StatusServerError(c)
+	if comment.Deleted {
+		utils.StatusConflict(c, "the comment has been deleted")
+		return
+	}
+
+	// only the body is written, so concurrent reactions keep their counts
+	result = a.db.Model(&comment).Updates(models.RenderBody(req.Body))
+	if err := result.Error; err != nil {
+		utils.StatusDBError(c, err)
 		return
 	}
 
@@ -105,7 +404,296 @@ func (a *CommentAPI) Delete(c *gin.Context) {
 		return
 	}
 
-	a.db.Delete(&comment)
+	if !a.mayChange(c, comment) {
+		utils.StatusForbidden(c, "only the author can delete the comment")
+		return
+	}
+
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		return removeComment(tx, comment)
+	})
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
 
 	utils.StatusOK(c, nil, "the comment has been deleted successfully")
 }
+
+func (a *CommentAPI) Trashed(c *gin.Context) {
+	fields, err := utils.ParseFields(c, commentFields, "")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	bodyFormat, err := utils.ParseBodyFormat(c)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	params, err := utils.ParsePageParams(c, commentFields)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	var post models.Post
+
+	if err := visiblePosts(c)(a.db).Select("id").First(&post, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err, "the post not found")
+		return
+	}
+
+	// moderators see the whole trash, the others only their own comments
+	isModerator := utils.IsModerator(c, a.db)
+
+	result, err := a.comments.Paginate(params, utils.Trashed, func(db *gorm.DB) *gorm.DB {
+		db = db.Where("post_id = ?", post.ID)
+		if !isModerator {
+			db = db.Where("user_id = ?", utils.GetUserID(c))
+		}
+		return db
+	}, fields.Scope)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			utils.StatusBadRequest(c, err.Error())
+			return
+		}
+
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	data, err := fields.Pick(result)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	data, err = bodyFormat.Pick(data)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
+}
+
+func (a *CommentAPI) EmptyTrash(c *gin.Context) {
+	var comment models.Comment
+
+	err := a.db.Unscoped().Where("post_id = ? AND deleted_at IS NOT NULL", c.Param("id")).First(&comment, c.Param("comment_id")).Error
+	if err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	if !a.mayChange(c, comment) {
+		utils.StatusForbidden(c, "only the author can delete the comment permanently")
+		return
+	}
+
+	err = a.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("target_type = ? AND target_id = ?", "comments", comment.ID).Delete(&models.Reaction{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&comment).Error
+	})
+	if err != nil {
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	utils.StatusOK(c, nil, "the comment has been deleted permanently")
+}
+
+// mayChange reports whether the current user may edit or delete the comment,
+// its author or a moderator.
+func (a *CommentAPI) mayChange(c *gin.Context, comment models.Comment) bool {
+	return comment.UserID == utils.GetUserID(c) || utils.IsModerator(c, a.db)
+}
+
+// Queue lists the comments awaiting moderation, or those in the moderation
+// state given by `?status=`, oldest first.
+func (a *CommentAPI) Queue(c *gin.Context) {
+	fields, err := utils.ParseFields(c, commentFields, "user")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	bodyFormat, err := utils.ParseBodyFormat(c)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	params, err := utils.ParsePageParams(c, commentFields)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	status := models.CommentStatus(c.DefaultQuery("status", string(models.CommentPending)))
+	switch status {
+	case models.CommentPending, models.CommentApproved, models.CommentRejected, models.CommentSpam:
+	default:
+		utils.StatusBadRequest(c, "status must be pending, approved, rejected or spam")
+		return
+	}
+
+	result, err := a.comments.Paginate(params, func(db *gorm.DB) *gorm.DB {
+		return db.Where("status = ?", status)
+	}, fields.Scope)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			utils.StatusBadRequest(c, err.Error())
+			return
+		}
+
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	data, err := fields.Pick(result)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	data, err = bodyFormat.Pick(data)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
+}
+
+// Moderate approves, rejects or marks as spam a batch of comments.
+func (a *CommentAPI) Moderate(c *gin.Context) {
+	var req models.CommentModerationRequest
+
+	if err := utils.ShouldBind(c, &req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	status := map[string]models.CommentStatus{
+		"approve": models.CommentApproved,
+		"reject":  models.CommentRejected,
+		"spam":    models.CommentSpam,
+	}[req.Action]
+
+	result := a.db.Model(&models.Comment{}).Where("id IN ?", req.Ids).Update("status", status)
+	if result.Error != nil {
+		utils.StatusDBError(c, result.Error)
+		return
+	}
+
+	utils.StatusOK(c, gin.H{"updated": result.RowsAffected}, fmt.Sprintf("%d comments are now %s", result.RowsAffected, status))
+}
+
+// initialStatus is pending when comments on the post need approval, globally
+// or by its category, unless a moderator writes them.
+func (a *CommentAPI) initialStatus(c *gin.Context, postID uint) (models.CommentStatus, error) {
+	if utils.IsModerator(c, a.db) {
+		return models.CommentApproved, nil
+	}
+
+	if a.cfg.CommentApproval {
+		return models.CommentPending, nil
+	}
+
+	var required bool
+
+	err := a.db.Model(&models.Category{}).
+		Joins("JOIN posts ON posts.category_id = categories.id").
+		Where("posts.id = ?", postID).
+		Select("categories.require_comment_approval").
+		Scan(&required).Error
+	if err != nil {
+		return "", err
+	}
+
+	if required {
+		return models.CommentPending, nil
+	}
+
+	return models.CommentApproved, nil
+}
+
+// removeComment deletes a comment. A comment with replies is kept as a
+// placeholder instead, and deleted placeholders left without replies are
+// removed up the thread.
+func removeComment(tx *gorm.DB, comment models.Comment) error {
+	if comment.ReplyCount > 0 {
+		updates := models.RenderBody(models.DeletedCommentBody)
+		updates["deleted"] = true
+
+		return tx.Model(&comment).Updates(updates).Error
+	}
+
+	for {
+		if err := tx.Delete(&comment).Error; err != nil {
+			return err
+		}
+
+		if comment.ParentID == nil {
+			return nil
+		}
+
+		var parent models.Comment
+
+		err := tx.Model(&parent).Clauses(clause.Returning{}).
+			Where("id = ?", *comment.ParentID).
+			UpdateColumn("reply_count", gorm.Expr("reply_count - 1")).Error
+		if err != nil {
+			return err
+		}
+
+		if !parent.Deleted || parent.ReplyCount > 0 {
+			return nil
+		}
+
+		comment = parent
+	}
+}
+
+// topLevelComments limits a query to the comments of a post that are not
+// replies.
+func topLevelComments(postID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("post_id = ? AND parent_id IS NULL", postID)
+	}
+}
+
+// visibleComments hides comments that are not approved from everyone but
+// their author and moderators.
+func visibleComments(c *gin.Context, db *gorm.DB) func(*gorm.DB) *gorm.DB {
+	if utils.IsModerator(c, db) {
+		return func(tx *gorm.DB) *gorm.DB {
+			return tx
+		}
+	}
+
+	userId := utils.GetUserID(c)
+
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("comments.status = ? OR comments.user_id = ?", models.CommentApproved, userId)
+	}
+}
+
+func commentsWithUser(db *gorm.DB) *gorm.DB {
+	return db.Preload("User", func(tx *gorm.DB) *gorm.DB {
+		return tx.Select("id", "name")
+	})
+}
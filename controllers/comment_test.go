@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"gin-rest-api/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCommentsOfInvisiblePost(t *testing.T) {
+	tests := []struct {
+		name, method, target, body string
+	}{
+		{"create", http.MethodPost, "/api/posts/5/comment/create", `{"postId":5,"body":"hi"}`},
+		{"thread", http.MethodGet, "/api/posts/5/comment/thread", ""},
+		{"reply", http.MethodPost, "/api/posts/5/comment/9/reply", `{"body":"hi"}`},
+		{"get", http.MethodGet, "/api/posts/5/comment/9/show", ""},
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, mock := mockDB(t)
+
+			// the draft of another user is not found, nothing else is read
+			mock.ExpectQuery(`SELECT "id" FROM "posts" WHERE \(status = \$1 OR user_id = \$2\) AND "posts"."id" = \$3`).
+				WithArgs("published", 7, sqlmock.AnyArg(), 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+			api := NewCommentAPI(&config.Config{CommentMaxDepth: 5}, db)
+			r := gin.New()
+			r.Use(func(c *gin.Context) { c.Set("userAuth", uint(7)) })
+			r.POST("/api/posts/:id/comment/create", api.Create)
+			r.GET("/api/posts/:id/comment/thread", api.Thread)
+			r.POST("/api/posts/:id/comment/:comment_id/reply", api.Reply)
+			r.GET("/api/posts/:id/comment/:comment_id/show", api.Get)
+
+			req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("got %d: %s", w.Code, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package controllers
+
+import (
+	"errors"
+	"gin-rest-api/config"
+	"gin-rest-api/models"
+	"gin-rest-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FollowAPI struct {
+	cfg        *config.Config
+	db         *gorm.DB
+	feed       *utils.Feed
+	users      *utils.Repository[models.User]
+	categories *utils.Repository[models.Category]
+	posts      *utils.Repository[models.Post]
+}
+
+func NewFollowAPI(cfg *config.Config, db *gorm.DB, rds *redis.Client) *FollowAPI {
+	return &FollowAPI{
+		cfg,
+		db,
+		utils.NewFeed(cfg, db, rds),
+		utils.NewRepository[models.User](cfg, db),
+		utils.NewRepository[models.Category](cfg, db),
+		utils.NewRepository[models.Post](cfg, db),
+	}
+}
+
+func (a *FollowAPI) FollowUser(c *gin.Context) {
+	var user models.User
+
+	if err := a.db.Select("id").First(&user, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	if user.ID == utils.GetUserID(c) {
+		utils.StatusUnprocessable(c, map[string]string{"id": "you cannot follow yourself"})
+		return
+	}
+
+	a.follow(c, models.FollowUsers, user.ID, "you are now following the user")
+}
+
+func (a *FollowAPI) UnfollowUser(c *gin.Context) {
+	a.unfollow(c, models.FollowUsers, "you no longer follow the user")
+}
+
+func (a *FollowAPI) FollowCategory(c *gin.Context) {
+	var category models.Category
+
+	if err := a.db.Select("id").First(&category, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	a.follow(c, models.FollowCategories, category.ID, "you are now following the category")
+}
+
+func (a *FollowAPI) UnfollowCategory(c *gin.Context) {
+	a.unfollow(c, models.FollowCategories, "you no longer follow the category")
+}
+
+func (a *FollowAPI) follow(c *gin.Context, targetType string, targetId uint, message string) {
+	follow := models.Follow{
+		FollowerID: utils.GetUserID(c),
+		TargetType: targetType,
+		TargetID:   targetId,
+	}
+
+	if err := a.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&follow).Error; err != nil {
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	if err := a.feed.Invalidate(c.Request.Context(), follow.FollowerID); err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, nil, message)
+}
+
+func (a *FollowAPI) unfollow(c *gin.Context, targetType string, message string) {
+	userId := utils.GetUserID(c)
+
+	result := a.db.Where("follower_id = ? AND target_type = ? AND target_id = ?", userId, targetType, c.Param("id")).
+		Delete(&models.Follow{})
+	if result.Error != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		utils.StatusNotFound(c, gorm.ErrRecordNotFound, "the follow not found")
+		return
+	}
+
+	if err := a.feed.Invalidate(c.Request.Context(), userId); err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, nil, message)
+}
+
+// Followers lists the users following a user. The total of the page is the
+// follower count.
+func (a *FollowAPI) Followers(c *gin.Context) {
+	a.userList(c, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN (SELECT follower_id FROM follows WHERE target_type = ? AND target_id = ?)", models.FollowUsers, c.Param("id"))
+	})
+}
+
+// Following lists the users a user follows.
+func (a *FollowAPI) Following(c *gin.Context) {
+	a.userList(c, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN (SELECT target_id FROM follows WHERE target_type = ? AND follower_id = ?)", models.FollowUsers, c.Param("id"))
+	})
+}
+
+// CategoryFollowers lists the users following a category.
+func (a *FollowAPI) CategoryFollowers(c *gin.Context) {
+	a.userList(c, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN (SELECT follower_id FROM follows WHERE target_type = ? AND target_id = ?)", models.FollowCategories, c.Param("id"))
+	})
+}
+
+func (a *FollowAPI) userList(c *gin.Context, scope func(*gorm.DB) *gorm.DB) {
+	resource := userResource(c, a.db, 0)
+
+	fields, err := utils.ParseFields(c, resource, "")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	params, err := utils.ParsePageParams(c, resource)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	result, err := a.users.Paginate(params, scope, fields.Scope)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			utils.StatusBadRequest(c, err.Error())
+			return
+		}
+
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	data, err := fields.Pick(result)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, hidePrivate(c, a.db, data, 0))
+}
+
+// FollowingCategories lists the categories a user follows.
+func (a *FollowAPI) FollowingCategories(c *gin.Context) {
+	fields, err := utils.ParseFields(c, categoryFields, "")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	params, err := utils.ParsePageParams(c, categoryFields)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	following := func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN (SELECT target_id FROM follows WHERE target_type = ? AND follower_id = ?)", models.FollowCategories, c.Param("id"))
+	}
+
+	result, err := a.categories.Paginate(params, following, fields.Scope)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			utils.StatusBadRequest(c, err.Error())
+			return
+		}
+
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	data, err := fields.Pick(result)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
+}
+
+// Feed lists the published posts of the authors and categories the current
+// user follows, newest first. It is always cursor paginated and reaches back
+// FEED_SIZE posts.
+func (a *FollowAPI) Feed(c *gin.Context) {
+	fields, err := utils.ParseFields(c, postFields, "category,user,tags")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	bodyFormat, err := utils.ParseBodyFormat(c)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	params, err := utils.ParsePageParams(c, utils.Resource{})
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	params.UseCursor = true
+	params.List = utils.SortBy(utils.Sort{Column: "published_at", Desc: true})
+
+	ids, err := a.feed.PostIDs(c.Request.Context(), utils.GetUserID(c))
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	inFeed := func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN ? AND status = ?", ids, models.PostPublished)
+	}
+
+	result, err := a.posts.Paginate(params, inFeed, fields.Scope)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			utils.StatusBadRequest(c, err.Error())
+			return
+		}
+
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	posts := make([]*models.Post, len(result.Data))
+	for i := range result.Data {
+		posts[i] = &result.Data[i]
+	}
+
+	if err := markBookmarked(c, a.db, posts...); err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	data, err := fields.Pick(result)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	data, err = bodyFormat.Pick(data)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
+}
+
+// followCounts returns the number of followers of a user and the number of
+// users and categories the user follows.
+func followCounts(db *gorm.DB, userId uint) (followers, following int64, err error) {
+	err = db.Model(&models.Follow{}).Where("target_type = ? AND target_id = ?", models.FollowUsers, userId).Count(&followers).Error
+	if err != nil {
+		return
+	}
+
+	err = db.Model(&models.Follow{}).Where("follower_id = ?", userId).Count(&following).Error
+	return
+}
@@ -1,29 +1,99 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"gin-rest-api/config"
+	"gin-rest-api/markdown"
 	"gin-rest-api/models"
+	"gin-rest-api/storage"
 	"gin-rest-api/utils"
+	"log"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/gosimple/slug"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
+var postFields = utils.Resource{
+	Fields: map[string]utils.Field{
+		"id":              {Column: "id", Key: "ID", Type: utils.FieldInt},
+		"title":           {Column: "title", Key: "title"},
+		"slug":            {Column: "slug", Key: "slug"},
+		"body":            {Column: "body", Key: "body"},
+		"body_html":       {Column: "body_html", Key: "body_html"},
+		"body_text":       {Column: "body_text", Key: "body_text"},
+		"status":          {Column: "status", Key: "status"},
+		"published_at":    {Column: "published_at", Key: "published_at", Type: utils.FieldTime},
+		"publish_at":      {Column: "publish_at", Key: "publish_at", Type: utils.FieldTime},
+		"expire_at":       {Column: "expire_at", Key: "expire_at", Type: utils.FieldTime},
+		"reaction_counts": {Column: "reaction_counts", Key: "reaction_counts"},
+		"view_count":      {Column: "view_count", Key: "view_count", Type: utils.FieldInt},
+		"bookmarked":      {Key: "bookmarked"},
+		"category_id":     {Column: "category_id", Key: "categoryID", Type: utils.FieldInt},
+		"user_id":         {Column: "user_id", Key: "userID", Type: utils.FieldInt},
+		"created_at":      {Column: "created_at", Key: "CreatedAt", Type: utils.FieldTime},
+		"updated_at":      {Column: "updated_at", Key: "UpdatedAt", Type: utils.FieldTime},
+		"deleted_at":      {Column: "deleted_at", Key: "DeletedAt", Type: utils.FieldTime},
+	},
+	Filters: []string{"id", "title", "slug", "status", "category_id", "user_id", "published_at", "view_count", "created_at", "updated_at", "deleted_at"},
+	Sorts:   []string{"id", "title", "published_at", "view_count", "created_at", "updated_at", "deleted_at"},
+	Relations: map[string]utils.Relation{
+		"category": {
+			Name: "Category", Key: "Category", LocalKey: "category_id", ForeignKey: "id",
+			Fields: map[string]utils.Field{
+				"id":   {Column: "id", Key: "ID"},
+				"name": {Column: "name", Key: "name"},
+				"slug": {Column: "slug", Key: "slug"},
+				"path": {Column: "path", Key: "path"},
+			},
+		},
+		"user": {
+			Name: "User", Key: "User", LocalKey: "user_id", ForeignKey: "id",
+			Fields: map[string]utils.Field{
+				"id":       {Column: "id", Key: "ID"},
+				"name":     {Column: "name", Key: "name"},
+				"username": {Column: "username", Key: "username"},
+			},
+		},
+		"tags": {
+			Name: "Tags", Key: "Tags", LocalKey: "id", ForeignKey: "id",
+			Fields: map[string]utils.Field{
+				"id":   {Column: "id", Key: "id"},
+				"name": {Column: "name", Key: "name"},
+				"slug": {Column: "slug", Key: "slug"},
+			},
+		},
+	},
+}
+
+const maxViewsDays = 366
+
 type PostAPI struct {
-	db *gorm.DB
+	cfg   *config.Config
+	db    *gorm.DB
+	feed  *utils.Feed
+	views *utils.Views
+	store storage.Storage
+	posts *utils.Repository[models.Post]
 }
 
-func NewPostAPI(db *gorm.DB) *PostAPI {
-	return &PostAPI{db}
+func NewPostAPI(cfg *config.Config, db *gorm.DB, rds *redis.Client, store storage.Storage) *PostAPI {
+	return &PostAPI{cfg, db, utils.NewFeed(cfg, db, rds), utils.NewViews(cfg, rds), store, utils.NewRepository[models.Post](cfg, db)}
 }
 
 func (a *PostAPI) Create(c *gin.Context) {
 	var req models.PostRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := utils.ShouldBind(c, &req); err != nil {
 		if errs, ok := err.(validator.ValidationErrors); ok {
-			utils.StatusUnprocessable(c, utils.FormatErrors(errs))
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
 			return
 		}
 
@@ -31,23 +101,35 @@ func (a *PostAPI) Create(c *gin.Context) {
 		return
 	}
 
-	if !utils.IsExist(a.db, "categories", "id", req.CategoryId) {
-		utils.StatusUnprocessable(c, map[string]any{
-			"CategoryId": "the category does not exist!",
-		})
-		return
-	}
-
 	post := models.Post{
 		Title:      req.Title,
 		Body:       req.Body,
+		Status:     models.PostDraft,
 		CategoryID: req.CategoryId,
 		UserID:     utils.GetUserID(c),
 	}
 
-	result := a.db.Create(&post)
-	if result.Error != nil {
-		utils.StatusServerError(c)
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		tags, err := models.FindOrCreateTags(tx, req.Tags)
+		if err != nil {
+			return err
+		}
+		post.Tags = tags
+
+		if err := models.CreateWithSlug(tx, &post); err != nil {
+			return err
+		}
+
+		return models.AddRevision(tx, &models.PostRevision{
+			PostID:     post.ID,
+			UserID:     post.UserID,
+			Title:      post.Title,
+			Body:       post.Body,
+			CategoryID: post.CategoryID,
+		}, a.cfg.RevisionLimit)
+	})
+	if err != nil {
+		utils.StatusDBError(c, err)
 		return
 	}
 
@@ -55,58 +137,186 @@ func (a *PostAPI) Create(c *gin.Context) {
 }
 
 func (a *PostAPI) Gets(c *gin.Context) {
-	var posts []models.Post
+	a.gets(c)
+}
 
-	pageStr := c.DefaultQuery("page", "1")
-	page, _ := strconv.Atoi(pageStr)
+// GetsByCategory lists the posts of a category and of all its subcategories.
+func (a *PostAPI) GetsByCategory(c *gin.Context) {
+	var category models.Category
 
-	perPageStr := c.DefaultQuery("perPage", "10")
-	perPage, _ := strconv.Atoi(perPageStr)
+	if err := a.db.First(&category, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
 
-	preloadFunc := func(query *gorm.DB) *gorm.DB {
-		return query.Preload("Category", func(db *gorm.DB) *gorm.DB {
-			return a.db.Select("id, name, slug")
-		}).Preload("User", func(db *gorm.DB) *gorm.DB {
-			return a.db.Select("id, name")
-		})
+	a.gets(c, inCategory(category.ID))
+}
+
+func (a *PostAPI) gets(c *gin.Context, scopes ...func(*gorm.DB) *gorm.DB) {
+	fields, err := utils.ParseFields(c, postFields, "category,user,tags")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	bodyFormat, err := utils.ParseBodyFormat(c)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	params, err := utils.ParsePageParams(c, postFields)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
 	}
 
-	result, err := utils.Paginate(a.db, page, perPage, preloadFunc, &posts)
+	tagged, err := taggedWith(c)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	scopes = append(scopes, visiblePosts(c), tagged, fields.Scope)
+
+	result, err := a.posts.Paginate(params, scopes...)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			utils.StatusBadRequest(c, err.Error())
+			return
+		}
+
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	posts := make([]*models.Post, len(result.Data))
+	for i := range result.Data {
+		posts[i] = &result.Data[i]
+	}
+
+	if err := markBookmarked(c, a.db, posts...); err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	data, err := fields.Pick(result)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	data, err = bodyFormat.Pick(data)
 	if err != nil {
 		utils.StatusServerError(c)
 		return
 	}
 
-	utils.StatusOK(c, result)
+	utils.StatusOK(c, data)
 }
 
 func (a *PostAPI) Get(c *gin.Context) {
-	var post models.Post
+	fields, err := utils.ParseFields(c, postFields, "category,user,tags")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	bodyFormat, err := utils.ParseBodyFormat(c)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
 
-	result := a.db.Preload("Category", func(db *gorm.DB) *gorm.DB {
-		return a.db.Select("id, name, slug")
-	}).Preload("User", func(db *gorm.DB) *gorm.DB {
-		return a.db.Select("id, name")
-	}).Preload("Comments", func(db *gorm.DB) *gorm.DB {
-		return a.db.Preload("User", func(db *gorm.DB) *gorm.DB {
-			return a.db.Select("id, name")
-		}).Select("id, post_id, user_id, body, created_at")
-	}).First(&post, c.Param("id"))
-
-	if err := result.Error; err != nil {
+	post, err := a.posts.First(c.Param("id"), visiblePosts(c), fields.Scope)
+	if err != nil {
 		utils.StatusNotFound(c, err)
 		return
 	}
 
-	utils.StatusOK(c, post)
+	if err := markBookmarked(c, a.db, &post); err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	a.countView(c, post)
+
+	data, err := fields.Pick(post)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	if err := a.addComments(c, data, post.ID); err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	data, err = bodyFormat.Pick(data)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
+}
+
+func (a *PostAPI) GetBySlug(c *gin.Context) {
+	fields, err := utils.ParseFields(c, postFields, "category,user,tags")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	bodyFormat, err := utils.ParseBodyFormat(c)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	post, err := a.posts.FirstBy("slug", c.Param("slug"), visiblePosts(c), fields.Scope)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) && utils.RedirectSlug(c, a.db, "posts", c.Param("slug")) {
+			return
+		}
+
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	if err := markBookmarked(c, a.db, &post); err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	a.countView(c, post)
+
+	data, err := fields.Pick(post)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	if err := a.addComments(c, data, post.ID); err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	data, err = bodyFormat.Pick(data)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
 }
 
 func (a *PostAPI) Update(c *gin.Context) {
 	var req models.PostRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := utils.ShouldBind(c, &req); err != nil {
 		if errs, ok := err.(validator.ValidationErrors); ok {
-			utils.StatusUnprocessable(c, utils.FormatErrors(errs))
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
 			return
 		}
 
@@ -116,69 +326,534 @@ func (a *PostAPI) Update(c *gin.Context) {
 
 	var post models.Post
 
-	result := a.db.First(&post, c.Param("id"))
-	if err := result.Error; err != nil {
+	if err := visiblePosts(c)(a.db).First(&post, c.Param("id")).Error; err != nil {
 		utils.StatusNotFound(c, err)
 		return
 	}
 
+	userId := utils.GetUserID(c)
+
+	if post.UserID != userId && !utils.IsModerator(c, a.db) {
+		utils.StatusForbidden(c, "only the author can update the post")
+		return
+	}
+
+	// the author stays the same when a moderator edits the post
 	updatePost := models.Post{
 		Title:      req.Title,
 		Body:       req.Body,
 		CategoryID: req.CategoryId,
-		UserID:     utils.GetUserID(c),
+		UserID:     post.UserID,
 	}
 
-	result = a.db.Model(&post).Updates(&updatePost)
-	if result.Error != nil {
-		utils.StatusServerError(c)
+	updatePost.BodyHTML, updatePost.BodyText = markdown.Render(req.Body)
+
+	changed := req.Title != post.Title || req.Body != post.Body || req.CategoryId != post.CategoryID
+
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		if req.Title != post.Title {
+			slug, err := models.RenameSlug(tx, "posts", post.ID, post.Slug, req.Title)
+			if err != nil {
+				return err
+			}
+			updatePost.Slug = slug
+		}
+
+		if err := tx.Model(&post).Updates(&updatePost).Error; err != nil {
+			return err
+		}
+
+		if changed {
+			err := models.AddRevision(tx, &models.PostRevision{
+				PostID:     post.ID,
+				UserID:     userId,
+				Title:      req.Title,
+				Body:       req.Body,
+				CategoryID: req.CategoryId,
+			}, a.cfg.RevisionLimit)
+			if err != nil {
+				return err
+			}
+		}
+
+		// tags are left alone when the request has none
+		if req.Tags == nil {
+			return nil
+		}
+
+		tags, err := models.FindOrCreateTags(tx, req.Tags)
+		if err != nil {
+			return err
+		}
+		updatePost.Tags = tags
+
+		return tx.Model(&post).Association("Tags").Replace(tags)
+	})
+	if err != nil {
+		utils.StatusDBError(c, err)
 		return
 	}
 
 	utils.StatusOK(c, updatePost)
 }
 
+func (a *PostAPI) Transition(c *gin.Context) {
+	var req models.PostStatusRequest
+
+	if err := utils.ShouldBind(c, &req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	var post models.Post
+
+	if err := visiblePosts(c)(a.db).First(&post, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	if post.UserID != utils.GetUserID(c) {
+		utils.StatusForbidden(c, "only the author can change the status of the post")
+		return
+	}
+
+	status := models.PostStatus(req.Status)
+	if !post.Status.CanTransition(status) {
+		utils.StatusUnprocessable(c, map[string]string{
+			"status": fmt.Sprintf("a %s post cannot be moved to %s", post.Status, status),
+		})
+		return
+	}
+
+	now := time.Now()
+	post.Status = status
+	post.PublishAt = nil
+
+	switch status {
+	case models.PostPublished:
+		post.PublishedAt = &now
+	case models.PostScheduled:
+		if !req.PublishAt.After(now) {
+			utils.StatusUnprocessable(c, map[string]string{"publishAt": "the publishAt must be in the future"})
+			return
+		}
+		post.PublishAt = req.PublishAt
+	case models.PostDraft, models.PostInReview:
+		post.PublishedAt = nil
+	}
+
+	post.ExpireAt = req.ExpireAt
+	if req.ExpireAt != nil {
+		start := now
+		if post.PublishAt != nil {
+			start = *post.PublishAt
+		}
+		if !req.ExpireAt.After(start) {
+			utils.StatusUnprocessable(c, map[string]string{"expireAt": "the expireAt must be after the publication"})
+			return
+		}
+	}
+
+	result := a.db.Model(&post).Select("status", "published_at", "publish_at", "expire_at").Updates(&post)
+	if result.Error != nil {
+		utils.StatusDBError(c, result.Error)
+		return
+	}
+
+	// a failed fan-out only delays the post in cached feeds until they expire
+	if status == models.PostPublished {
+		if err := a.feed.Push(c.Request.Context(), post); err != nil {
+			log.Printf("feed push: %s\n", err)
+		}
+	}
+
+	utils.StatusOK(c, post, "the post is now "+string(status))
+}
+
 func (a *PostAPI) Delete(c *gin.Context) {
 	var post models.Post
 
-	result := a.db.First(&post, c.Param("id"))
-	if err := result.Error; err != nil {
+	if err := visiblePosts(c)(a.db).First(&post, c.Param("id")).Error; err != nil {
 		utils.StatusNotFound(c, err)
 		return
 	}
 
-	a.db.Delete(&post)
+	if post.UserID != utils.GetUserID(c) && !utils.IsModerator(c, a.db) {
+		utils.StatusForbidden(c, "only the author can delete the post")
+		return
+	}
+
+	if err := a.db.Delete(&post).Error; err != nil {
+		utils.StatusDBError(c, err)
+		return
+	}
 
 	utils.StatusOK(c, nil, "the post has been deleted successfully")
 }
 
+// Restore takes a trashed post out of the trash, for its author or a
+// moderator. Its author and category must not be in the trash.
+func (a *PostAPI) Restore(c *gin.Context) {
+	restoreOne(c, a.db, "post", a.restore(c))
+}
+
+// RestoreMany restores the trashed posts of `ids`.
+func (a *PostAPI) RestoreMany(c *gin.Context) {
+	restoreMany(c, a.db, "post", a.restore(c))
+}
+
+func (a *PostAPI) restore(c *gin.Context) restoreFunc {
+	return func(tx *gorm.DB, id uint) (map[string]string, error) {
+		var post models.Post
+
+		if err := firstTrashed(tx, &post, id); err != nil {
+			return nil, err
+		}
+
+		if post.UserID != utils.GetUserID(c) && !utils.IsModerator(c, a.db) {
+			return nil, errRestoreForbidden
+		}
+
+		conflicts := map[string]string{}
+
+		live, err := taken(tx, &models.User{}, "id", post.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !live {
+			conflicts["user_id"] = "the author of the post is in the trash"
+		}
+
+		live, err = taken(tx, &models.Category{}, "id", post.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		if !live {
+			conflicts["category_id"] = "the category of the post is in the trash"
+		}
+
+		if len(conflicts) > 0 {
+			return conflicts, nil
+		}
+
+		return nil, untrash(tx, &models.Post{}, "id = ?", post.ID)
+	}
+}
+
 func (a *PostAPI) Trashed(c *gin.Context) {
-	var posts []models.Post
+	fields, err := utils.ParseFields(c, postFields, "")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	bodyFormat, err := utils.ParseBodyFormat(c)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	params, err := utils.ParsePageParams(c, postFields)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	result, err := a.posts.Paginate(params, utils.Trashed, visiblePosts(c), fields.Scope)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			utils.StatusBadRequest(c, err.Error())
+			return
+		}
+
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	data, err := fields.Pick(result)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	data, err = bodyFormat.Pick(data)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
+}
+
+// addComments adds the comment count and the first page of top-level
+// comments to a picked post. The other pages are served by CommentAPI.Gets.
+func (a *PostAPI) addComments(c *gin.Context, data any, postID uint) error {
+	post, ok := data.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	var count int64
+
+	visible := visibleComments(c, a.db)
+
+	err := visible(a.db.Model(&models.Comment{})).Where("post_id = ? AND deleted = ?", postID, false).Count(&count).Error
+	if err != nil {
+		return err
+	}
+
+	params := utils.PageParams{Page: 1, PerPage: utils.DefaultPerPage, List: commentOrder}
+
+	comments, err := utils.Paginate[models.Comment](a.db, params, topLevelComments(postID), visible, commentsWithUser)
+	if err != nil {
+		return err
+	}
+
+	post["comment_count"] = count
+	post["comments"] = comments
+	return nil
+}
+
+// Views returns the daily views of a post from `from` to `to`, the last 30
+// days by default, to its author or a moderator. Views of the last minutes
+// show up once they are flushed.
+func (a *PostAPI) Views(c *gin.Context) {
+	var req models.PostViewsRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	var post models.Post
+
+	if err := visiblePosts(c)(a.db).Select("id", "user_id").First(&post, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
 
-	pageStr := c.DefaultQuery("page", "1")
-	page, _ := strconv.Atoi(pageStr)
+	if post.UserID != utils.GetUserID(c) && !utils.IsModerator(c, a.db) {
+		utils.StatusForbidden(c, "only the author can see the views of the post")
+		return
+	}
 
-	perPageStr := c.DefaultQuery("perPage", "10")
-	perPage, _ := strconv.Atoi(perPageStr)
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if req.To != "" {
+		to, _ = time.Parse(time.DateOnly, req.To)
+	}
 
-	result, err := utils.Paginate(a.db.Unscoped().Where("deleted_at IS NOT NULL"), page, perPage, nil, &posts)
+	from := to.AddDate(0, 0, -29)
+	if req.From != "" {
+		from, _ = time.Parse(time.DateOnly, req.From)
+	}
+
+	if from.After(to) {
+		utils.StatusUnprocessable(c, map[string]string{"from": "from must not be after to"})
+		return
+	}
+
+	if to.Sub(from) > maxViewsDays*24*time.Hour {
+		utils.StatusUnprocessable(c, map[string]string{"from": fmt.Sprintf("at most %d days can be read at once", maxViewsDays)})
+		return
+	}
+
+	var stats []models.PostDailyView
+
+	err := a.db.Where("post_id = ? AND date BETWEEN ? AND ?", post.ID, from, to).Find(&stats).Error
 	if err != nil {
 		utils.StatusServerError(c)
 		return
 	}
 
-	utils.StatusOK(c, result)
+	counts := make(map[string]int64, len(stats))
+	for _, stat := range stats {
+		counts[stat.Date.Format(time.DateOnly)] = stat.Views
+	}
+
+	// days without views are listed with 0
+	days := []models.DailyViews{}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(time.DateOnly)
+		days = append(days, models.DailyViews{Date: date, Views: counts[date]})
+	}
+
+	utils.StatusOK(c, days)
+}
+
+// countView counts a view of the post by the current user, or by the client
+// address without one. Authors reading their own posts are not counted, and a
+// failure only loses the view.
+func (a *PostAPI) countView(c *gin.Context, post models.Post) {
+	userId := utils.GetUserID(c)
+	if userId != 0 && userId == post.UserID {
+		return
+	}
+
+	viewer := "ip_" + c.ClientIP()
+	if userId != 0 {
+		viewer = "user_" + strconv.Itoa(int(userId))
+	}
+
+	if err := a.views.Count(c.Request.Context(), post.ID, viewer); err != nil {
+		log.Printf("view count: %s\n", err)
+	}
+}
+
+// markBookmarked sets Bookmarked on the posts the current user bookmarked.
+func markBookmarked(c *gin.Context, db *gorm.DB, posts ...*models.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	var bookmarked []uint
+
+	err := db.Model(&models.Bookmark{}).
+		Where("user_id = ? AND post_id IN ?", utils.GetUserID(c), ids).
+		Pluck("post_id", &bookmarked).Error
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		post.Bookmarked = slices.Contains(bookmarked, post.ID)
+	}
+
+	return nil
+}
+
+// visiblePosts limits a query to published posts and the current user's own.
+func visiblePosts(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	userId := utils.GetUserID(c)
+
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("status = ? OR user_id = ?", models.PostPublished, userId)
+	}
+}
+
+// inCategory limits a query to the posts of a category and its subcategories.
+func inCategory(id uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`category_id IN (
+			WITH RECURSIVE tree AS (
+				SELECT id FROM categories WHERE id = ? AND deleted_at IS NULL
+				UNION ALL
+				SELECT categories.id FROM categories JOIN tree ON categories.parent_id = tree.id
+				WHERE categories.deleted_at IS NULL
+			)
+			SELECT id FROM tree
+		)`, id)
+	}
+}
+
+// taggedWith limits a query to the posts tagged with any, or with all when
+// tagMatch=all, of the comma separated tag slugs of `?tags=`.
+func taggedWith(c *gin.Context) (func(*gorm.DB) *gorm.DB, error) {
+	slugs := []string{}
+	for _, name := range strings.Split(c.Query("tags"), ",") {
+		if tagSlug := slug.Make(name); tagSlug != "" && !slices.Contains(slugs, tagSlug) {
+			slugs = append(slugs, tagSlug)
+		}
+	}
+
+	match := c.DefaultQuery("tagMatch", "any")
+	if match != "any" && match != "all" {
+		return nil, errors.New("tagMatch must be any or all")
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		if len(slugs) == 0 {
+			return db
+		}
+
+		tagged := db.Session(&gorm.Session{NewDB: true}).Table("post_tags").
+			Select("post_tags.post_id").
+			Joins("JOIN tags ON tags.id = post_tags.tag_id").
+			Where("tags.slug IN ?", slugs)
+
+		if match == "all" {
+			tagged = tagged.Group("post_tags.post_id").Having("count(*) = ?", len(slugs))
+		}
+
+		return db.Where("id IN (?)", tagged)
+	}, nil
 }
 
 func (a *PostAPI) EmptyTrash(c *gin.Context) {
 	var post models.Post
 
-	if err := a.db.Unscoped().First(&post, c.Param("id")).Error; err != nil {
+	if err := a.db.Unscoped().Where("deleted_at IS NOT NULL").First(&post, c.Param("id")).Error; err != nil {
 		utils.StatusNotFound(c, err)
 		return
 	}
 
-	a.db.Unscoped().Delete(&post)
+	if post.UserID != utils.GetUserID(c) && !utils.IsModerator(c, a.db) {
+		utils.StatusForbidden(c, "only the author can delete the post permanently")
+		return
+	}
+
+	var attachments []models.Attachment
+
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("post_id = ?", post.ID).Find(&attachments).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("post_id = ?", post.ID).Delete(&models.Attachment{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("target_type = ? AND target_id = ?", "posts", post.ID).Delete(&models.Reaction{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("post_id = ?", post.ID).Delete(&models.Bookmark{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("post_id = ?", post.ID).Delete(&models.PostRevision{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("post_id = ?", post.ID).Delete(&models.PostDailyView{}).Error; err != nil {
+			return err
+		}
+
+		// the comments go too, trashed ones and replies included
+		comments := tx.Unscoped().Model(&models.Comment{}).Select("id").Where("post_id = ?", post.ID)
+		if err := tx.Where("target_type = ? AND target_id IN (?)", "comments", comments).Delete(&models.Reaction{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("post_id = ?", post.ID).Delete(&models.Comment{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Select("Tags").Delete(&post).Error
+	})
+	if err != nil {
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	// the files go once the records are gone for good
+	for _, attachment := range attachments {
+		storage.Remove(c.Request.Context(), a.store, attachment.Keys()...)
+	}
 
 	utils.StatusOK(c, nil, "the post has been deleted permanently")
 }
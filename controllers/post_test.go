@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"gin-rest-api/config"
+	"gin-rest-api/storage"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+func TestEmptyTrashPurgesComments(t *testing.T) {
+	db, mock := mockDB(t)
+
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE deleted_at IS NOT NULL AND "posts"."id" = \$1`).
+		WithArgs("5", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "title"}).AddRow(5, 7, "trashed"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "attachments" WHERE post_id = \$1`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`DELETE FROM "attachments" WHERE post_id = \$1`).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "reactions" WHERE target_type = \$1 AND target_id = \$2`).WithArgs("posts", 5).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "bookmarks" WHERE post_id = \$1`).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "post_revisions" WHERE post_id = \$1`).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "post_daily_views" WHERE post_id = \$1`).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "reactions" WHERE target_type = \$1 AND target_id IN \(SELECT "id" FROM "comments" WHERE post_id = \$2\)`).
+		WithArgs("comments", 5).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM "comments" WHERE post_id = \$1`).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`DELETE FROM "post_tags" WHERE "post_tags"."post_id" = \$1`).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "posts" WHERE "posts"."id" = \$1`).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.DELETE("/posts/:id/trash", func(c *gin.Context) {
+		c.Set("userAuth", uint(7))
+		NewPostAPI(&config.Config{}, db, nil, store).EmptyTrash(c)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/posts/5/trash", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
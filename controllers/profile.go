@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"bytes"
+	"gin-rest-api/config"
+	"gin-rest-api/models"
+	"gin-rest-api/storage"
+	"gin-rest-api/utils"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+const avatarSize = 240
+
+type ProfileAPI struct {
+	cfg   *config.Config
+	db    *gorm.DB
+	store storage.Storage
+}
+
+func NewProfileAPI(cfg *config.Config, db *gorm.DB, store storage.Storage) *ProfileAPI {
+	return &ProfileAPI{cfg, db, store}
+}
+
+// Get returns the public profile of a user with the counts of the user's
+// published posts, approved comments and follows.
+func (a *ProfileAPI) Get(c *gin.Context) {
+	var user models.User
+
+	if err := a.db.Where("username = ?", c.Param("username")).First(&user).Error; err != nil {
+		utils.StatusNotFound(c, err, "the profile not found")
+		return
+	}
+
+	profile, err := a.profile(user)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, profile)
+}
+
+func (a *ProfileAPI) profile(user models.User) (models.Profile, error) {
+	profile := models.Profile{
+		ID:        user.ID,
+		Username:  user.Username,
+		Name:      user.Name,
+		Bio:       user.Bio,
+		Website:   user.Website,
+		AvatarURL: "/api/profiles/" + user.Username + "/avatar",
+		JoinedAt:  user.CreatedAt,
+	}
+
+	err := a.db.Model(&models.Post{}).Where("user_id = ? AND status = ?", user.ID, models.PostPublished).
+		Count(&profile.Stats.Posts).Error
+	if err != nil {
+		return profile, err
+	}
+
+	err = a.db.Model(&models.Comment{}).
+		Where("user_id = ? AND status = ? AND deleted = ?", user.ID, models.CommentApproved, false).
+		Count(&profile.Stats.Comments).Error
+	if err != nil {
+		return profile, err
+	}
+
+	profile.Stats.Followers, profile.Stats.Following, err = followCounts(a.db, user.ID)
+	return profile, err
+}
+
+// Avatar serves the thumbnail of the image the user picked as avatar, or an
+// identicon generated from the username.
+func (a *ProfileAPI) Avatar(c *gin.Context) {
+	var user models.User
+
+	if err := a.db.Select("id", "username", "avatar_id").Where("username = ?", c.Param("username")).First(&user).Error; err != nil {
+		utils.StatusNotFound(c, err, "the profile not found")
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=3600")
+
+	if user.AvatarID != nil {
+		var avatar models.Attachment
+
+		if err := a.db.First(&avatar, *user.AvatarID).Error; err == nil {
+			reader, err := a.store.Open(c.Request.Context(), avatar.ThumbnailKey)
+			if err == nil {
+				defer reader.Close()
+				c.DataFromReader(http.StatusOK, -1, utils.ThumbnailType(avatar.ContentType), reader, nil)
+				return
+			}
+		}
+	}
+
+	var identicon bytes.Buffer
+
+	if err := utils.Identicon(&identicon, user.Username, avatarSize); err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	c.Data(http.StatusOK, "image/png", identicon.Bytes())
+}
+
+// Update changes the username, bio and website of the current user.
+func (a *ProfileAPI) Update(c *gin.Context) {
+	var req models.ProfileRequest
+
+	if err := utils.ShouldBind(c, &req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	var user models.User
+
+	if err := a.db.First(&user, utils.GetUserID(c)).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	user.Username, user.Bio, user.Website = req.Username, req.Bio, req.Website
+
+	result := a.db.Model(&user).Select("username", "bio", "website").Updates(&user)
+	if result.Error != nil {
+		utils.StatusDBError(c, result.Error)
+		return
+	}
+
+	profile, err := a.profile(user)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, profile, "the profile has been updated")
+}
+
+// SetAvatar makes one of the current user's uploaded images the avatar, or
+// goes back to the identicon when attachmentId is null.
+func (a *ProfileAPI) SetAvatar(c *gin.Context) {
+	var req models.AvatarRequest
+
+	if err := utils.ShouldBind(c, &req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	userId := utils.GetUserID(c)
+
+	if req.AttachmentId != nil {
+		var avatar models.Attachment
+
+		if err := a.db.Where("user_id = ?", userId).First(&avatar, *req.AttachmentId).Error; err != nil {
+			utils.StatusNotFound(c, err, "the attachment not found")
+			return
+		}
+
+		if !slices.Contains(thumbnailTypes, avatar.ContentType) {
+			utils.StatusUnprocessable(c, map[string]string{"attachmentId": "the avatar must be a JPEG, PNG or GIF image"})
+			return
+		}
+	}
+
+	result := a.db.Model(&models.User{}).Where("id = ?", userId).Update("avatar_id", req.AttachmentId)
+	if result.Error != nil {
+		utils.StatusDBError(c, result.Error)
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		utils.StatusNotFound(c, gorm.ErrRecordNotFound)
+		return
+	}
+
+	utils.StatusOK(c, nil, "the avatar has been updated")
+}
@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"errors"
+	"gin-rest-api/config"
+	"gin-rest-api/models"
+	"gin-rest-api/utils"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var reactionFields = utils.Resource{
+	Fields: map[string]utils.Field{
+		"id":         {Column: "id", Key: "id", Type: utils.FieldInt},
+		"user_id":    {Column: "user_id", Key: "user_id", Type: utils.FieldInt},
+		"type":       {Column: "type", Key: "type"},
+		"created_at": {Column: "created_at", Key: "created_at", Type: utils.FieldTime},
+	},
+	Filters:     []string{"type", "user_id"},
+	Sorts:       []string{"created_at"},
+	DefaultSort: "-created_at",
+	Relations: map[string]utils.Relation{
+		"user": {
+			Name: "User", Key: "user", LocalKey: "user_id", ForeignKey: "id",
+			Fields: map[string]utils.Field{
+				"id":       {Column: "id", Key: "ID"},
+				"name":     {Column: "name", Key: "name"},
+				"username": {Column: "username", Key: "username"},
+			},
+		},
+	},
+}
+
+type ReactionAPI struct {
+	cfg       *config.Config
+	db        *gorm.DB
+	reactions *utils.Repository[models.Reaction]
+}
+
+func NewReactionAPI(cfg *config.Config, db *gorm.DB) *ReactionAPI {
+	return &ReactionAPI{cfg, db, utils.NewRepository[models.Reaction](cfg, db)}
+}
+
+func (a *ReactionAPI) TogglePost(c *gin.Context) {
+	if id, ok := a.findPost(c); ok {
+		a.toggle(c, "posts", id)
+	}
+}
+
+func (a *ReactionAPI) ToggleComment(c *gin.Context) {
+	if id, ok := a.findComment(c); ok {
+		a.toggle(c, "comments", id)
+	}
+}
+
+func (a *ReactionAPI) GetsPost(c *gin.Context) {
+	if id, ok := a.findPost(c); ok {
+		a.gets(c, "posts", id)
+	}
+}
+
+func (a *ReactionAPI) GetsComment(c *gin.Context) {
+	if id, ok := a.findComment(c); ok {
+		a.gets(c, "comments", id)
+	}
+}
+
+// toggle adds the user's reaction of the requested type to the target, or
+// removes it when it exists. The counter of the target changes in the same
+// transaction and only when a reaction row was really inserted or deleted,
+// so concurrent toggles keep it exact.
+func (a *ReactionAPI) toggle(c *gin.Context, table string, id uint) {
+	var req models.ReactionRequest
+
+	if err := utils.ShouldBind(c, &req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	if !slices.Contains(a.cfg.ReactionTypes, req.Type) {
+		utils.StatusUnprocessable(c, map[string]string{
+			"type": "the type must be one of " + strings.Join(a.cfg.ReactionTypes, ", "),
+		})
+		return
+	}
+
+	reaction := models.Reaction{
+		TargetType: table,
+		TargetID:   id,
+		Type:       req.Type,
+		UserID:     utils.GetUserID(c),
+	}
+
+	reacted := false
+	var counts models.ReactionCounts
+
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("target_type = ? AND target_id = ? AND type = ? AND user_id = ?",
+			table, id, req.Type, reaction.UserID).Delete(&models.Reaction{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		delta := -1
+		if result.RowsAffected == 0 {
+			result = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&reaction)
+			if result.Error != nil {
+				return result.Error
+			}
+			delta = int(result.RowsAffected)
+		}
+
+		reacted = delta > 0
+
+		if delta != 0 {
+			err := tx.Table(table).Where("id = ?", id).UpdateColumn("reaction_counts", gorm.Expr(
+				"jsonb_set(reaction_counts, ARRAY[?::text], to_jsonb(GREATEST(COALESCE((reaction_counts->>?::text)::bigint, 0) + ?, 0)))",
+				req.Type, req.Type, delta,
+			)).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Table(table).Where("id = ?", id).Select("reaction_counts").Row().Scan(&counts)
+	})
+	if err != nil {
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	utils.StatusOK(c, gin.H{"reacted": reacted, "type": req.Type, "reaction_counts": counts})
+}
+
+// gets lists who reacted to the target, newest first.
+func (a *ReactionAPI) gets(c *gin.Context, table string, id uint) {
+	fields, err := utils.ParseFields(c, reactionFields, "user")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	params, err := utils.ParsePageParams(c, reactionFields)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	result, err := a.reactions.Paginate(params, func(db *gorm.DB) *gorm.DB {
+		return db.Where("target_type = ? AND target_id = ?", table, id)
+	}, fields.Scope)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			utils.StatusBadRequest(c, err.Error())
+			return
+		}
+
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	data, err := fields.Pick(result)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
+}
+
+func (a *ReactionAPI) findPost(c *gin.Context) (uint, bool) {
+	var post models.Post
+
+	if err := visiblePosts(c)(a.db).Select("id").First(&post, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return 0, false
+	}
+
+	return post.ID, true
+}
+
+func (a *ReactionAPI) findComment(c *gin.Context) (uint, bool) {
+	var comment models.Comment
+
+	err := visibleComments(c, a.db)(a.db).Select("id").
+		Where("post_id = ? AND NOT deleted", c.Param("id")).
+		First(&comment, c.Param("comment_id")).Error
+	if err != nil {
+		utils.StatusNotFound(c, err)
+		return 0, false
+	}
+
+	return comment.ID, true
+}
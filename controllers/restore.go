@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"gin-rest-api/models"
+	"gin-rest-api/utils"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errRestoreConflict  = errors.New("restore conflict")
+	errRestoreForbidden = errors.New("restore forbidden")
+)
+
+// restoreFunc brings back a trashed record and the records that were trashed
+// together with it. When a unique value of the record was taken in the
+// meantime, it restores nothing and returns the conflicts by field.
+type restoreFunc func(tx *gorm.DB, id uint) (map[string]string, error)
+
+// restoreOne restores the trashed record of the id param.
+func restoreOne(c *gin.Context, db *gorm.DB, resource string, restore restoreFunc) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		utils.StatusNotFound(c, gorm.ErrRecordNotFound)
+		return
+	}
+
+	restoreAll(c, db, resource, []uint{uint(id)}, restore)
+}
+
+// restoreMany restores the trashed records of the ids of the request, all
+// of them or none.
+func restoreMany(c *gin.Context, db *gorm.DB, resource string, restore restoreFunc) {
+	var req models.RestoreRequest
+
+	if err := utils.ShouldBind(c, &req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	restoreAll(c, db, resource, req.Ids, restore)
+}
+
+func restoreAll(c *gin.Context, db *gorm.DB, resource string, ids []uint, restore restoreFunc) {
+	ids = slices.Compact(slices.Sorted(slices.Values(ids)))
+
+	var failed uint
+	conflicts := map[uint]map[string]string{}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, id := range ids {
+			conflict, err := restore(tx, id)
+			if err != nil {
+				failed = id
+				return err
+			}
+
+			if len(conflict) > 0 {
+				conflicts[id] = conflict
+			}
+		}
+
+		if len(conflicts) > 0 {
+			return errRestoreConflict
+		}
+		return nil
+	})
+
+	switch {
+	case err == nil:
+		utils.StatusOK(c, map[string][]uint{"ids": ids}, fmt.Sprintf("the %s has been restored successfully", resource))
+	case errors.Is(err, errRestoreConflict):
+		if len(ids) == 1 {
+			utils.StatusConflict(c, conflicts[ids[0]])
+			return
+		}
+		utils.StatusConflict(c, conflicts)
+	case errors.Is(err, errRestoreForbidden):
+		utils.StatusForbidden(c, fmt.Sprintf("you cannot restore the %s %d", resource, failed))
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		utils.StatusNotFound(c, err, fmt.Sprintf("the %s %d is not in the trash", resource, failed))
+	default:
+		utils.StatusDBError(c, err)
+	}
+}
+
+// firstTrashed loads the trashed record of id into dest.
+func firstTrashed(tx *gorm.DB, dest any, id uint) error {
+	return tx.Unscoped().Where("deleted_at IS NOT NULL").First(dest, id).Error
+}
+
+// taken reports whether a record of model that is not trashed has value in
+// column.
+func taken(tx *gorm.DB, model any, column string, value any) (bool, error) {
+	var count int64
+
+	err := tx.Model(model).Where(clause.Eq{Column: clause.Column{Name: column}, Value: value}).Count(&count).Error
+	return count > 0, err
+}
+
+// untrash takes the rows of model matching the conditions out of the trash.
+func untrash(tx *gorm.DB, model any, query string, args ...any) error {
+	return tx.Unscoped().Model(model).Where(query, args...).UpdateColumn("deleted_at", nil).Error
+}
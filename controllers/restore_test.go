@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func mockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, mock
+}
+
+func TestRestoreAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		ids      []uint
+		results  map[uint]error
+		conflict map[uint]map[string]string
+		commit   bool
+		code     int
+		restored []uint
+		message  string
+	}{
+		{
+			name:     "deduplicated",
+			ids:      []uint{3, 1, 3},
+			commit:   true,
+			code:     http.StatusOK,
+			restored: []uint{1, 3},
+		},
+		{
+			name:     "one conflict rolls back all",
+			ids:      []uint{1, 2},
+			conflict: map[uint]map[string]string{2: {"email": "taken"}},
+			code:     http.StatusConflict,
+			restored: []uint{1, 2},
+			message:  `"2":{"email":"taken"}`,
+		},
+		{
+			name:     "single conflict",
+			ids:      []uint{2},
+			conflict: map[uint]map[string]string{2: {"email": "taken"}},
+			code:     http.StatusConflict,
+			restored: []uint{2},
+			message:  `"message":{"email":"taken"}`,
+		},
+		{
+			name:     "not trashed",
+			ids:      []uint{1, 4, 5},
+			results:  map[uint]error{4: gorm.ErrRecordNotFound},
+			code:     http.StatusNotFound,
+			restored: []uint{1, 4},
+			message:  "the post 4 is not in the trash",
+		},
+		{
+			name:     "forbidden",
+			ids:      []uint{6},
+			results:  map[uint]error{6: errRestoreForbidden},
+			code:     http.StatusForbidden,
+			restored: []uint{6},
+			message:  "you cannot restore the post 6",
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, mock := mockDB(t)
+			mock.ExpectBegin()
+			if test.commit {
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			var restored []uint
+			restore := func(tx *gorm.DB, id uint) (map[string]string, error) {
+				restored = append(restored, id)
+				return test.conflict[id], test.results[id]
+			}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+			restoreAll(c, db, "post", test.ids, restore)
+
+			if w.Code != test.code {
+				t.Fatalf("got %d: %s", w.Code, w.Body)
+			}
+			if !reflect.DeepEqual(restored, test.restored) {
+				t.Fatalf("restored %v, want %v", restored, test.restored)
+			}
+			if !strings.Contains(w.Body.String(), test.message) {
+				t.Fatalf("%s is missing from %s", test.message, w.Body)
+			}
+			if test.commit {
+				var res struct{ Data map[string][]uint }
+				json.Unmarshal(w.Body.Bytes(), &res)
+				if !reflect.DeepEqual(res.Data["ids"], test.restored) {
+					t.Fatalf("got %s", w.Body)
+				}
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"gin-rest-api/config"
+	"gin-rest-api/models"
+	"gin-rest-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+var revisionFields = utils.Resource{
+	Fields: map[string]utils.Field{
+		"id":          {Column: "id", Key: "id", Type: utils.FieldInt},
+		"number":      {Column: "number", Key: "number", Type: utils.FieldInt},
+		"user_id":     {Column: "user_id", Key: "user_id", Type: utils.FieldInt},
+		"title":       {Column: "title", Key: "title"},
+		"body":        {Column: "body", Key: "body"},
+		"category_id": {Column: "category_id", Key: "category_id", Type: utils.FieldInt},
+		"created_at":  {Column: "created_at", Key: "created_at", Type: utils.FieldTime},
+	},
+	Filters:     []string{"user_id", "category_id", "created_at"},
+	Sorts:       []string{"number", "created_at"},
+	DefaultSort: "-number",
+	Relations: map[string]utils.Relation{
+		"user": {
+			Name: "User", Key: "user", LocalKey: "user_id", ForeignKey: "id",
+			Fields: map[string]utils.Field{
+				"id":       {Column: "id", Key: "ID"},
+				"name":     {Column: "name", Key: "name"},
+				"username": {Column: "username", Key: "username"},
+			},
+		},
+	},
+}
+
+const revisionDiffContext = 3
+
+type RevisionAPI struct {
+	cfg       *config.Config
+	db        *gorm.DB
+	revisions *utils.Repository[models.PostRevision]
+}
+
+func NewRevisionAPI(cfg *config.Config, db *gorm.DB) *RevisionAPI {
+	return &RevisionAPI{cfg, db, utils.NewRepository[models.PostRevision](cfg, db)}
+}
+
+func (a *RevisionAPI) Gets(c *gin.Context) {
+	post, ok := a.post(c)
+	if !ok {
+		return
+	}
+
+	fields, err := utils.ParseFields(c, revisionFields, "user")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	params, err := utils.ParsePageParams(c, revisionFields)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	result, err := a.revisions.Paginate(params, ofPost(post.ID), fields.Scope)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			utils.StatusBadRequest(c, err.Error())
+			return
+		}
+
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	data, err := fields.Pick(result)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
+}
+
+func (a *RevisionAPI) Get(c *gin.Context) {
+	post, ok := a.post(c)
+	if !ok {
+		return
+	}
+
+	fields, err := utils.ParseFields(c, revisionFields, "user")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	revision, err := a.revisions.FirstBy("number", c.Param("number"), ofPost(post.ID), fields.Scope)
+	if err != nil {
+		utils.StatusNotFound(c, err, "the revision not found")
+		return
+	}
+
+	data, err := fields.Pick(revision)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
+}
+
+// Diff compares two revisions of a post. The title is compared word by word
+// and the body as a unified diff, or word by word with mode=word.
+func (a *RevisionAPI) Diff(c *gin.Context) {
+	var req models.RevisionDiffRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	post, ok := a.post(c)
+	if !ok {
+		return
+	}
+
+	from, err := a.revisions.FirstBy("number", req.From, ofPost(post.ID))
+	if err != nil {
+		utils.StatusNotFound(c, err, "the revision "+fmt.Sprint(req.From)+" not found")
+		return
+	}
+
+	to, err := a.revisions.FirstBy("number", req.To, ofPost(post.ID))
+	if err != nil {
+		utils.StatusNotFound(c, err, "the revision "+fmt.Sprint(req.To)+" not found")
+		return
+	}
+
+	mode := req.Mode
+	if mode == "" {
+		mode = "unified"
+	}
+
+	title, err := utils.WordDiff(from.Title, to.Title)
+	if err != nil {
+		utils.StatusUnprocessable(c, map[string]string{"title": err.Error()})
+		return
+	}
+
+	var body any
+
+	if mode == "word" {
+		body, err = utils.WordDiff(from.Body, to.Body)
+	} else {
+		body, err = utils.UnifiedDiff(
+			fmt.Sprintf("revision %d", from.Number), fmt.Sprintf("revision %d", to.Number),
+			from.Body, to.Body, revisionDiffContext,
+		)
+	}
+	if err != nil {
+		utils.StatusUnprocessable(c, map[string]string{"body": err.Error()})
+		return
+	}
+
+	diff := map[string]any{
+		"from":  from.Number,
+		"to":    to.Number,
+		"mode":  mode,
+		"title": title,
+		"body":  body,
+	}
+
+	if from.CategoryID != to.CategoryID {
+		diff["category_id"] = map[string]uint{"from": from.CategoryID, "to": to.CategoryID}
+	}
+
+	utils.StatusOK(c, diff)
+}
+
+// Restore brings the title, body and category of a post back to a revision,
+// recording the result as a new revision.
+func (a *RevisionAPI) Restore(c *gin.Context) {
+	var post models.Post
+
+	if err := visiblePosts(c)(a.db).First(&post, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	userId := utils.GetUserID(c)
+
+	if post.UserID != userId && !utils.IsModerator(c, a.db) {
+		utils.StatusForbidden(c, "only the author can restore a revision of the post")
+		return
+	}
+
+	revision, err := a.revisions.FirstBy("number", c.Param("number"), ofPost(post.ID))
+	if err != nil {
+		utils.StatusNotFound(c, err, "the revision not found")
+		return
+	}
+
+	restored := models.PostRevision{
+		PostID:     post.ID,
+		UserID:     userId,
+		Title:      revision.Title,
+		Body:       revision.Body,
+		CategoryID: revision.CategoryID,
+	}
+
+	err = a.db.Transaction(func(tx *gorm.DB) error {
+		updates := models.RenderBody(revision.Body)
+		updates["title"] = revision.Title
+		updates["category_id"] = revision.CategoryID
+
+		if revision.Title != post.Title {
+			slug, err := models.RenameSlug(tx, "posts", post.ID, post.Slug, revision.Title)
+			if err != nil {
+				return err
+			}
+			updates["slug"] = slug
+		}
+
+		if err := tx.Model(&post).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		return models.AddRevision(tx, &restored, a.cfg.RevisionLimit)
+	})
+	if err != nil {
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	utils.StatusOK(c, restored, fmt.Sprintf("the post has been restored to revision %d", revision.Number))
+}
+
+// post loads the post of the request when the current user may read its
+// history, its author or a moderator, and answers otherwise.
+func (a *RevisionAPI) post(c *gin.Context) (models.Post, bool) {
+	var post models.Post
+
+	if err := visiblePosts(c)(a.db).Select("id", "user_id").First(&post, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return post, false
+	}
+
+	if post.UserID != utils.GetUserID(c) && !utils.IsModerator(c, a.db) {
+		utils.StatusForbidden(c, "only the author can see the revisions of the post")
+		return post, false
+	}
+
+	return post, true
+}
+
+func ofPost(postID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("post_id = ?", postID)
+	}
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"gin-rest-api/config"
+	"gin-rest-api/models"
+	"gin-rest-api/utils"
+	"html"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// snippets are highlighted between private use characters, which are only
+// turned into <mark> tags once the text around them is escaped
+const (
+	highlightStart  = "\uE000"
+	highlightStop   = "\uE001"
+	headlineOptions = "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", MaxFragments=2, MaxWords=30, MinWords=10"
+)
+
+var highlighter = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+
+type SearchAPI struct {
+	cfg *config.Config
+	db  *gorm.DB
+}
+
+func NewSearchAPI(cfg *config.Config, db *gorm.DB) *SearchAPI {
+	return &SearchAPI{cfg, db}
+}
+
+func (a *SearchAPI) Search(c *gin.Context) {
+	var req models.SearchRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	// results are ranked, so only offset pagination applies
+	params, err := utils.ParsePageParams(c, utils.Resource{})
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	var sources []any
+	if req.Type == "" || req.Type == "posts" {
+		sources = append(sources, a.searchPosts(req))
+	}
+	if req.Type == "" || req.Type == "comments" {
+		sources = append(sources, a.searchComments(req))
+	}
+
+	query := a.db.Table("(?) AS results", sources[0])
+	if len(sources) == 2 {
+		query = a.db.Table("((?) UNION ALL (?)) AS results", sources...)
+	}
+
+	result, err := utils.Paginate[models.SearchResult](query, params, func(db *gorm.DB) *gorm.DB {
+		return db.Order("rank DESC, created_at DESC")
+	})
+	if err != nil {
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	for i := range result.Data {
+		result.Data[i].Snippet = highlight(result.Data[i].Snippet)
+	}
+
+	utils.StatusOK(c, result)
+}
+
+// highlight makes an HTML snippet of a headline of the plain text of a body.
+func highlight(headline string) string {
+	return highlighter.Replace(html.EscapeString(headline))
+}
+
+func (a *SearchAPI) searchPosts(req models.SearchRequest) *gorm.DB {
+	query := a.db.Table("posts, websearch_to_tsquery(?::regconfig, ?) AS query", a.cfg.SearchLanguage, req.Q).
+		Select(`'post' AS type, posts.id, posts.id AS post_id, posts.title,
+			ts_headline(?::regconfig, posts.body_text, query, ?) AS snippet,
+			ts_rank(posts.search, query) AS rank, posts.created_at`, a.cfg.SearchLanguage, headlineOptions).
+		Where("posts.search @@ query AND posts.status = ? AND posts.deleted_at IS NULL", models.PostPublished)
+
+	if req.CategoryId > 0 {
+		query = query.Where("posts.category_id = ?", req.CategoryId)
+	}
+	if req.UserId > 0 {
+		query = query.Where("posts.user_id = ?", req.UserId)
+	}
+
+	return query
+}
+
+func (a *SearchAPI) searchComments(req models.SearchRequest) *gorm.DB {
+	query := a.db.Table("comments JOIN posts ON posts.id = comments.post_id, websearch_to_tsquery(?::regconfig, ?) AS query", a.cfg.SearchLanguage, req.Q).
+		Select(`'comment' AS type, comments.id, comments.post_id, posts.title,
+			ts_headline(?::regconfig, comments.body_text, query, ?) AS snippet,
+			ts_rank(comments.search, query) AS rank, comments.created_at`, a.cfg.SearchLanguage, headlineOptions).
+		Where("comments.search @@ query AND comments.status = ? AND NOT comments.deleted AND comments.deleted_at IS NULL", models.CommentApproved).
+		Where("posts.status = ? AND posts.deleted_at IS NULL", models.PostPublished)
+
+	if req.CategoryId > 0 {
+		query = query.Where("posts.category_id = ?", req.CategoryId)
+	}
+	if req.UserId > 0 {
+		query = query.Where("comments.user_id = ?", req.UserId)
+	}
+
+	return query
+}
@@ -0,0 +1,13 @@
+package controllers
+
+import "testing"
+
+func TestHighlight(t *testing.T) {
+	headline := `a <img src=x onerror=alert(1)> ` + highlightStart + `match` + highlightStop + ` & "more"`
+
+	got := highlight(headline)
+	want := `a &lt;img src=x onerror=alert(1)&gt; <mark>match</mark> &amp; &#34;more&#34;`
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"errors"
+	"gin-rest-api/config"
+	"gin-rest-api/models"
+	"gin-rest-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gosimple/slug"
+	"gorm.io/gorm"
+)
+
+var tagFields = utils.Resource{
+	Fields: map[string]utils.Field{
+		"id":         {Column: "id", Key: "id", Type: utils.FieldInt},
+		"name":       {Column: "name", Key: "name"},
+		"slug":       {Column: "slug", Key: "slug"},
+		"post_count": {Column: "post_count", Key: "post_count", Type: utils.FieldInt},
+		"created_at": {Column: "created_at", Key: "created_at", Type: utils.FieldTime},
+		"updated_at": {Column: "updated_at", Key: "updated_at", Type: utils.FieldTime},
+	},
+	Filters: []string{"id", "name", "slug", "post_count", "created_at"},
+	Sorts:   []string{"id", "name", "post_count", "created_at"},
+}
+
+type TagAPI struct {
+	cfg  *config.Config
+	db   *gorm.DB
+	tags *utils.Repository[models.Tag]
+}
+
+func NewTagAPI(cfg *config.Config, db *gorm.DB) *TagAPI {
+	return &TagAPI{cfg, db, utils.NewRepository[models.Tag](cfg, db)}
+}
+
+func (a *TagAPI) Gets(c *gin.Context) {
+	fields, err := utils.ParseFields(c, tagFields, "")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	params, err := utils.ParsePageParams(c, tagFields)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	result, err := a.tags.Paginate(params, a.withPostCount, fields.Scope)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			utils.StatusBadRequest(c, err.Error())
+			return
+		}
+
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	data, err := fields.Pick(result)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
+}
+
+func (a *TagAPI) Update(c *gin.Context) {
+	var req models.TagRequest
+
+	if err := utils.ShouldBind(c, &req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	var tag models.Tag
+
+	if err := a.db.First(&tag, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	tagSlug := slug.Make(req.Name)
+	if tagSlug == "" {
+		utils.StatusUnprocessable(c, map[string]string{"name": "the name must contain letters or digits"})
+		return
+	}
+
+	result := a.db.Model(&tag).Updates(models.Tag{Name: req.Name, Slug: tagSlug})
+	if result.Error != nil {
+		utils.StatusDBError(c, result.Error)
+		return
+	}
+
+	utils.StatusOK(c, tag)
+}
+
+// Merge moves the posts of a tag to the target tag and deletes it.
+func (a *TagAPI) Merge(c *gin.Context) {
+	var req models.TagMergeRequest
+
+	if err := utils.ShouldBind(c, &req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	var tag, target models.Tag
+
+	if err := a.db.First(&tag, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	if err := a.db.First(&target, req.TargetId).Error; err != nil {
+		utils.StatusNotFound(c, err, "the target tag not found")
+		return
+	}
+
+	if tag.ID == target.ID {
+		utils.StatusUnprocessable(c, map[string]string{"targetId": "a tag cannot be merged into itself"})
+		return
+	}
+
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT INTO post_tags (post_id, tag_id)
+			SELECT post_id, ? FROM post_tags WHERE tag_id = ?
+			ON CONFLICT DO NOTHING`, target.ID, tag.ID).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Exec("DELETE FROM post_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&tag).Error
+	})
+	if err != nil {
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	utils.StatusOK(c, target, "the tag has been merged successfully")
+}
+
+// withPostCount lists tags with the number of published posts using them.
+func (a *TagAPI) withPostCount(db *gorm.DB) *gorm.DB {
+	counts := a.db.Model(&models.Tag{}).
+		Select("tags.*, count(posts.id) AS post_count").
+		Joins("LEFT JOIN post_tags ON post_tags.tag_id = tags.id").
+		Joins("LEFT JOIN posts ON posts.id = post_tags.post_id AND posts.status = ? AND posts.deleted_at IS NULL", models.PostPublished).
+		Group("tags.id")
+
+	return db.Table("(?) AS tags", counts)
+}
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"gin-rest-api/config"
 	"gin-rest-api/models"
 	"gin-rest-api/utils"
@@ -14,22 +16,58 @@ import (
 	"gorm.io/gorm"
 )
 
+var userFields = utils.Resource{
+	Fields: map[string]utils.Field{
+		"id":         {Column: "id", Key: "ID", Type: utils.FieldInt},
+		"name":       {Column: "name", Key: "name"},
+		"username":   {Column: "username", Key: "username"},
+		"email":      {Column: "email", Key: "email"},
+		"role":       {Column: "role", Key: "role"},
+		"bio":        {Column: "bio", Key: "bio"},
+		"website":    {Column: "website", Key: "website"},
+		"avatar_id":  {Column: "avatar_id", Key: "avatar_id"},
+		"created_at": {Column: "created_at", Key: "CreatedAt", Type: utils.FieldTime},
+		"updated_at": {Column: "updated_at", Key: "UpdatedAt", Type: utils.FieldTime},
+		"deleted_at": {Column: "deleted_at", Key: "DeletedAt", Type: utils.FieldTime},
+	},
+	Filters: []string{"id", "name", "username", "email", "role", "created_at", "updated_at", "deleted_at"},
+	Sorts:   []string{"id", "name", "username", "email", "created_at", "updated_at", "deleted_at"},
+	Relations: map[string]utils.Relation{
+		"posts": {
+			Name: "Posts", Key: "Posts", LocalKey: "id", ForeignKey: "user_id",
+			Scope: visiblePosts,
+			Fields: map[string]utils.Field{
+				"id":          {Column: "id", Key: "ID"},
+				"title":       {Column: "title", Key: "title"},
+				"slug":        {Column: "slug", Key: "slug"},
+				"category_id": {Column: "category_id", Key: "categoryID"},
+				"user_id":     {Column: "user_id", Key: "userID"},
+				"created_at":  {Column: "created_at", Key: "CreatedAt"},
+			},
+		},
+	},
+}
+
+// privateUserFields are only shown to the user themselves and to admins.
+var privateUserFields = []string{"email"}
+
 type UserAPI struct {
-	cfg *config.Config
-	db  *gorm.DB
-	rds *redis.Client
+	cfg   *config.Config
+	db    *gorm.DB
+	rds   *redis.Client
+	users *utils.Repository[models.User]
 }
 
 func NewUserAPI(cfg *config.Config, db *gorm.DB, rds *redis.Client) *UserAPI {
-	return &UserAPI{cfg, db, rds}
+	return &UserAPI{cfg, db, rds, utils.NewRepository[models.User](cfg, db)}
 }
 
 func (a *UserAPI) Register(c *gin.Context) {
 	var req models.RegisterRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := utils.ShouldBind(c, &req); err != nil {
 		if errs, ok := err.(validator.ValidationErrors); ok {
-			utils.StatusUnprocessable(c, utils.FormatErrors(errs))
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
 			return
 		}
 
@@ -37,13 +75,6 @@ func (a *UserAPI) Register(c *gin.Context) {
 		return
 	}
 
-	if utils.IsExist(a.db, "users", "email", req.Email) {
-		utils.StatusUnprocessable(c, map[string]any{
-			"Email": "the email is already exist!",
-		})
-		return
-	}
-
 	hashPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), 10)
 	if err != nil {
 		utils.StatusServerError(c, "failed to hash password")
@@ -52,13 +83,14 @@ func (a *UserAPI) Register(c *gin.Context) {
 
 	user := models.User{
 		Name:     req.Name,
+		Username: req.Username,
 		Email:    req.Email,
 		Password: string(hashPassword),
 	}
 
 	result := a.db.Create(&user)
 	if result.Error != nil {
-		utils.StatusServerError(c)
+		utils.StatusDBError(c, result.Error)
 		return
 	}
 
@@ -68,7 +100,7 @@ func (a *UserAPI) Register(c *gin.Context) {
 func (a *UserAPI) Login(c *gin.Context) {
 	var req models.LoginRequest
 
-	if c.ShouldBindJSON(&req) != nil {
+	if utils.ShouldBind(c, &req) != nil {
 		utils.StatusBadRequest(c, "failed to read body")
 		return
 	}
@@ -110,53 +142,101 @@ func (a *UserAPI) Logout(c *gin.Context) {
 }
 
 func (a *UserAPI) Me(c *gin.Context) {
-	var user models.User
+	fields, err := utils.ParseFields(c, userFields, "")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
 
-	result := a.db.First(&user, utils.GetUserID(c))
-	if err := result.Error; err != nil {
+	user, err := a.users.First(utils.GetUserID(c), fields.Scope)
+	if err != nil {
 		utils.StatusNotFound(c, err)
 		return
 	}
 
-	utils.StatusOK(c, user)
+	data, err := fields.Pick(user)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	utils.StatusOK(c, data)
 }
 
 func (a *UserAPI) Gets(c *gin.Context) {
-	var users []models.User
+	resource := userResource(c, a.db, 0)
+
+	fields, err := utils.ParseFields(c, resource, "")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	params, err := utils.ParsePageParams(c, resource)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
 
-	pageStr := c.DefaultQuery("page", "1")
-	page, _ := strconv.Atoi(pageStr)
+	result, err := a.users.Paginate(params, fields.Scope)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			utils.StatusBadRequest(c, err.Error())
+			return
+		}
 
-	perPageStr := c.DefaultQuery("perPage", "10")
-	perPage, _ := strconv.Atoi(perPageStr)
+		utils.StatusDBError(c, err)
+		return
+	}
 
-	result, err := utils.Paginate(a.db, page, perPage, nil, &users)
+	data, err := fields.Pick(result)
 	if err != nil {
 		utils.StatusServerError(c)
 		return
 	}
 
-	utils.StatusOK(c, result)
+	utils.StatusOK(c, hidePrivate(c, a.db, data, 0))
 }
 
 func (a *UserAPI) Get(c *gin.Context) {
-	var user models.User
+	fields, err := utils.ParseFields(c, userResource(c, a.db, c.Param("id")), "")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
 
-	result := a.db.First(&user, c.Param("id"))
-	if err := result.Error; err != nil {
+	user, err := a.users.First(c.Param("id"), fields.Scope)
+	if err != nil {
 		utils.StatusNotFound(c, err)
 		return
 	}
 
-	utils.StatusOK(c, user)
+	data, err := fields.Pick(user)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	followers, following, err := followCounts(a.db, user.ID)
+	if err != nil {
+		utils.StatusServerError(c)
+		return
+	}
+
+	if user, ok := data.(map[string]any); ok {
+		user["follower_count"] = followers
+		user["following_count"] = following
+	}
+
+	utils.StatusOK(c, hidePrivate(c, a.db, data, user.ID))
 }
 
 func (a *UserAPI) Update(c *gin.Context) {
 	var req models.UserUpdateRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := utils.ShouldBind(c, &req); err != nil {
 		if errs, ok := err.(validator.ValidationErrors); ok {
-			utils.StatusUnprocessable(c, utils.FormatErrors(errs))
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
 			return
 		}
 
@@ -172,13 +252,6 @@ func (a *UserAPI) Update(c *gin.Context) {
 		return
 	}
 
-	if user.Email != req.Email && utils.IsExist(a.db, "users", "email", req.Email) {
-		utils.StatusUnprocessable(c, map[string]any{
-			"Email": "the email is already exist!",
-		})
-		return
-	}
-
 	updateUser := models.User{
 		Name:  req.Name,
 		Email: req.Email,
@@ -186,13 +259,41 @@ func (a *UserAPI) Update(c *gin.Context) {
 
 	result = a.db.Model(&user).Updates(&updateUser)
 	if result.Error != nil {
-		utils.StatusServerError(c)
+		utils.StatusDBError(c, result.Error)
 		return
 	}
 
 	utils.StatusOK(c, user)
 }
 
+func (a *UserAPI) UpdateRole(c *gin.Context) {
+	var req models.UserRoleRequest
+
+	if err := utils.ShouldBind(c, &req); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			utils.StatusUnprocessable(c, utils.FormatErrors(c, errs))
+			return
+		}
+
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	var user models.User
+
+	if err := a.db.First(&user, c.Param("id")).Error; err != nil {
+		utils.StatusNotFound(c, err)
+		return
+	}
+
+	if err := a.db.Model(&user).Update("role", req.Role).Error; err != nil {
+		utils.StatusDBError(c, err)
+		return
+	}
+
+	utils.StatusOK(c, user, "the role has been updated successfully")
+}
+
 func (a *UserAPI) Delete(c *gin.Context) {
 	var user models.User
 
@@ -202,38 +303,166 @@ func (a *UserAPI) Delete(c *gin.Context) {
 		return
 	}
 
-	a.db.Delete(&user)
+	if user.ID != utils.GetUserID(c) && utils.GetUserRole(c, a.db) != models.RoleAdmin {
+		utils.StatusForbidden(c, "only the user or an admin can delete the user")
+		return
+	}
+
+	// the posts of the user are trashed at the same time so that they come
+	// back with the user
+	now := time.Now()
+
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Post{}).Where("user_id = ?", user.ID).UpdateColumn("deleted_at", now).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&user).UpdateColumn("deleted_at", now).Error
+	})
+	if err != nil {
+		utils.StatusDBError(c, err)
+		return
+	}
 
 	utils.StatusOK(c, nil, "the user has been deleted successfully")
 }
 
+// Restore takes a trashed user out of the trash with the posts that were
+// trashed with the user.
+func (a *UserAPI) Restore(c *gin.Context) {
+	restoreOne(c, a.db, "user", a.restore)
+}
+
+// RestoreMany restores the trashed users of `ids`.
+func (a *UserAPI) RestoreMany(c *gin.Context) {
+	restoreMany(c, a.db, "user", a.restore)
+}
+
+func (a *UserAPI) restore(tx *gorm.DB, id uint) (map[string]string, error) {
+	var user models.User
+
+	if err := firstTrashed(tx, &user, id); err != nil {
+		return nil, err
+	}
+
+	conflicts := map[string]string{}
+
+	for column, value := range map[string]string{"email": user.Email, "username": user.Username} {
+		isTaken, err := taken(tx, &models.User{}, column, value)
+		if err != nil {
+			return nil, err
+		}
+		if isTaken {
+			conflicts[column] = "the " + column + " has been taken by another user"
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return conflicts, nil
+	}
+
+	if err := untrash(tx, &models.Post{}, "user_id = ? AND deleted_at = ?", user.ID, user.DeletedAt); err != nil {
+		return nil, err
+	}
+
+	return nil, untrash(tx, &models.User{}, "id = ?", user.ID)
+}
+
 func (a *UserAPI) Trashed(c *gin.Context) {
-	var users []models.User
+	fields, err := utils.ParseFields(c, userFields, "")
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
+
+	params, err := utils.ParsePageParams(c, userFields)
+	if err != nil {
+		utils.StatusBadRequest(c, err.Error())
+		return
+	}
 
-	pageStr := c.DefaultQuery("page", "1")
-	page, _ := strconv.Atoi(pageStr)
+	result, err := a.users.Paginate(params, utils.Trashed, fields.Scope)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			utils.StatusBadRequest(c, err.Error())
+			return
+		}
 
-	perPageStr := c.DefaultQuery("perPage", "10")
-	perPage, _ := strconv.Atoi(perPageStr)
+		utils.StatusDBError(c, err)
+		return
+	}
 
-	result, err := utils.Paginate(a.db.Unscoped().Where("deleted_at IS NOT NULL"), page, perPage, nil, &users)
+	data, err := fields.Pick(result)
 	if err != nil {
 		utils.StatusServerError(c)
 		return
 	}
 
-	utils.StatusOK(c, result)
+	utils.StatusOK(c, data)
 }
 
 func (a *UserAPI) EmptyTrash(c *gin.Context) {
 	var user models.User
 
-	if err := a.db.Unscoped().First(&user, c.Param("id")).Error; err != nil {
+	if err := a.db.Unscoped().Where("deleted_at IS NOT NULL").First(&user, c.Param("id")).Error; err != nil {
 		utils.StatusNotFound(c, err)
 		return
 	}
 
-	a.db.Unscoped().Delete(&user)
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("follower_id = ? OR (target_type = ? AND target_id = ?)", user.ID, models.FollowUsers, user.ID).
+			Delete(&models.Follow{}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&user).Error
+	})
+	if err != nil {
+		utils.StatusDBError(c, err)
+		return
+	}
 
 	utils.StatusOK(c, nil, "the user has been deleted permanently")
 }
+
+// userResource is userFields, without the private fields unless the current
+// user is an admin or the user of id.
+func userResource(c *gin.Context, db *gorm.DB, id any) utils.Resource {
+	if mayReadPrivate(c, db, id) {
+		return userFields
+	}
+	return userFields.Without(privateUserFields...)
+}
+
+// hidePrivate drops the private fields from data, a user or a page of users,
+// unless the current user is an admin or the user of id.
+func hidePrivate(c *gin.Context, db *gorm.DB, data any, id any) any {
+	if mayReadPrivate(c, db, id) {
+		return data
+	}
+
+	record, ok := data.(map[string]any)
+	if !ok {
+		return data
+	}
+
+	records := []any{record}
+	if page, ok := record["data"].([]any); ok {
+		records = page
+	}
+
+	for _, user := range records {
+		if user, ok := user.(map[string]any); ok {
+			for _, name := range privateUserFields {
+				delete(user, userFields.Fields[name].Key)
+			}
+		}
+	}
+
+	return data
+}
+
+func mayReadPrivate(c *gin.Context, db *gorm.DB, id any) bool {
+	return fmt.Sprint(id) == fmt.Sprint(utils.GetUserID(c)) || utils.GetUserRole(c, db) == models.RoleAdmin
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"gin-rest-api/config"
 	"gin-rest-api/models"
 	"log"
+	"regexp"
 )
 
 func main() {
@@ -17,13 +19,36 @@ func main() {
 		log.Fatal(err)
 	}
 
-	err = db.Migrator().DropTable(models.User{}, models.Category{}, models.Post{}, models.Comment{})
+	err = db.Migrator().DropTable("post_tags", models.Bookmark{}, models.User{}, models.Category{}, models.Post{}, models.Comment{}, models.Tag{}, models.Reaction{}, models.BookmarkCollection{}, models.Follow{}, models.PostRevision{}, models.Attachment{}, models.SlugHistory{}, models.PostDailyView{})
 	if err != nil {
 		log.Fatal("table dropping failed")
 	}
 
-	err = db.AutoMigrate(models.User{}, models.Category{}, models.Post{}, models.Comment{})
+	err = db.AutoMigrate(models.User{}, models.Category{}, models.Post{}, models.Comment{}, models.Tag{}, models.Reaction{}, models.BookmarkCollection{}, models.Bookmark{}, models.Follow{}, models.PostRevision{}, models.Attachment{}, models.SlugHistory{}, models.PostDailyView{})
 	if err != nil {
 		log.Fatal("migration failed")
 	}
+
+	if !regexp.MustCompile(`^[a-z_]+$`).MatchString(cfg.SearchLanguage) {
+		log.Fatal("invalid search language")
+	}
+
+	// full-text search columns, titles rank above bodies
+	searchColumns := []string{
+		fmt.Sprintf(`ALTER TABLE posts ADD COLUMN search tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('%[1]s', coalesce(title, '')), 'A') ||
+			setweight(to_tsvector('%[1]s', coalesce(body, '')), 'B')
+		) STORED`, cfg.SearchLanguage),
+		"CREATE INDEX idx_posts_search ON posts USING GIN (search)",
+		fmt.Sprintf(`ALTER TABLE comments ADD COLUMN search tsvector GENERATED ALWAYS AS (
+			to_tsvector('%s', coalesce(body, ''))
+		) STORED`, cfg.SearchLanguage),
+		"CREATE INDEX idx_comments_search ON comments USING GIN (search)",
+	}
+
+	for _, sql := range searchColumns {
+		if err := db.Exec(sql).Error; err != nil {
+			log.Fatal("search migration failed: ", err)
+		}
+	}
 }
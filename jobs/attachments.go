@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"context"
+	"gin-rest-api/models"
+	"gin-rest-api/storage"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const cleanBatchSize = 100
+
+// CleanAttachments deletes the uploads that were neither linked to a post
+// nor made an avatar within ttl, with their files.
+func CleanAttachments(db *gorm.DB, store storage.Storage, ttl time.Duration) func(now time.Time) error {
+	return func(now time.Time) error {
+		for {
+			var attachments []models.Attachment
+
+			err := db.Where("post_id IS NULL AND created_at < ?", now.Add(-ttl)).
+				Where("id NOT IN (SELECT avatar_id FROM users WHERE avatar_id IS NOT NULL)").
+				Order("id").Limit(cleanBatchSize).Find(&attachments).Error
+			if err != nil || len(attachments) == 0 {
+				return err
+			}
+
+			if err := db.Delete(&attachments).Error; err != nil {
+				return err
+			}
+
+			for _, attachment := range attachments {
+				storage.Remove(context.Background(), store, attachment.Keys()...)
+			}
+
+			if len(attachments) < cleanBatchSize {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Run calls job every interval until ctx is done. Failures are logged and
+// retried on the next tick.
+func Run(ctx context.Context, name string, interval time.Duration, job func(now time.Time) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := job(now); err != nil {
+				log.Printf("%s: %s\n", name, err)
+			}
+		}
+	}
+}
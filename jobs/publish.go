@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"context"
+	"gin-rest-api/models"
+	"gin-rest-api/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PublishPosts publishes the scheduled posts that are due, pushing them into
+// the cached feeds, and archives the published posts that have expired.
+func PublishPosts(db *gorm.DB, feed *utils.Feed) func(now time.Time) error {
+	return func(now time.Time) error {
+		var published []models.Post
+
+		err := db.Model(&published).Clauses(clause.Returning{}).
+			Where("status = ? AND publish_at <= ?", models.PostScheduled, now).
+			Updates(map[string]any{
+				"status":       models.PostPublished,
+				"published_at": gorm.Expr("publish_at"),
+				"publish_at":   nil,
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		err = db.Model(&models.Post{}).
+			Where("status = ? AND expire_at <= ?", models.PostPublished, now).
+			Update("status", models.PostArchived).Error
+		if err != nil {
+			return err
+		}
+
+		return feed.Push(context.Background(), published...)
+	}
+}
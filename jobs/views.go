@@ -0,0 +1,87 @@
+package jobs
+
+import (
+	"context"
+	"gin-rest-api/models"
+	"gin-rest-api/utils"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// viewsFlushTimeout bounds a flush, after which another instance may take
+// over the lock.
+const viewsFlushTimeout = 5 * time.Minute
+
+// FlushViews adds the views buffered in Redis to the view counts of the posts
+// and to their daily stats. Views of posts deleted in the meantime are
+// dropped. Only one instance flushes at a time.
+func FlushViews(db *gorm.DB, views *utils.Views) func(now time.Time) error {
+	return func(now time.Time) error {
+		ctx := context.Background()
+
+		unlock, ok, err := views.Lock(ctx, viewsFlushTimeout)
+		if err != nil || !ok {
+			return err
+		}
+		defer unlock()
+
+		// a flush outliving the lock could be stored twice
+		flushCtx, cancel := context.WithTimeout(ctx, viewsFlushTimeout)
+		defer cancel()
+
+		pending, err := views.Pending(flushCtx)
+		if err != nil || len(pending) == 0 {
+			return err
+		}
+
+		totals := map[uint]int64{}
+		ids := []uint{}
+		for _, view := range pending {
+			if _, ok := totals[view.PostID]; !ok {
+				ids = append(ids, view.PostID)
+			}
+			totals[view.PostID] += view.Views
+		}
+
+		err = db.WithContext(flushCtx).Transaction(func(tx *gorm.DB) error {
+			var existing []uint
+
+			if err := tx.Unscoped().Model(&models.Post{}).Where("id IN ?", ids).Pluck("id", &existing).Error; err != nil {
+				return err
+			}
+
+			var stats []models.PostDailyView
+			for _, view := range pending {
+				if slices.Contains(existing, view.PostID) {
+					stats = append(stats, view)
+				}
+			}
+
+			for _, id := range existing {
+				err := tx.Unscoped().Model(&models.Post{}).Where("id = ?", id).
+					UpdateColumn("view_count", gorm.Expr("view_count + ?", totals[id])).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			if len(stats) == 0 {
+				return nil
+			}
+
+			return tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "post_id"}, {Name: "date"}},
+				DoUpdates: clause.Assignments(map[string]any{"views": gorm.Expr("post_daily_views.views + excluded.views")}),
+			}).Create(&stats).Error
+		})
+		if err != nil {
+			return err
+		}
+
+		// a failed ack stores the batch twice, a lesser evil than losing it
+		return views.Ack(ctx)
+	}
+}
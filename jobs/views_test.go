@@ -0,0 +1,88 @@
+package jobs
+
+import (
+	"context"
+	"gin-rest-api/config"
+	"gin-rest-api/utils"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestFlushViews(t *testing.T) {
+	server := miniredis.RunT(t)
+	rds := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer rds.Close()
+
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	views := utils.NewViews(&config.Config{ViewWindow: 1800}, rds)
+	flush := FlushViews(db, views)
+
+	for _, view := range []struct {
+		post   uint
+		viewer string
+	}{{1, "a"}, {1, "b"}, {2, "a"}} {
+		if err := views.Count(ctx, view.post, view.viewer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("locked", func(t *testing.T) {
+		unlock, ok, err := views.Lock(ctx, time.Minute)
+		if err != nil || !ok {
+			t.Fatalf("got %v, %v, want the lock", ok, err)
+		}
+		defer unlock()
+
+		// another instance holds the lock, nothing is flushed
+		if err := flush(time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("flushed", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT "id" FROM "posts" WHERE id IN`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec(`UPDATE "posts" SET "view_count"=view_count \+ \$1 WHERE id = \$2`).
+			WithArgs(2, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO "post_daily_views" .* ON CONFLICT \("post_id","date"\) DO UPDATE SET "views"=post_daily_views.views \+ excluded.views`).
+			WithArgs(1, sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := flush(time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+
+		// the batch was acknowledged and the lock released
+		pending, err := views.Pending(ctx)
+		if err != nil || len(pending) != 0 {
+			t.Fatalf("got %v, %v after the flush", pending, err)
+		}
+		if server.Exists("views_flush_lock") {
+			t.Fatal("the lock was not released")
+		}
+	})
+}
@@ -3,7 +3,10 @@ package main
 import (
 	"context"
 	"gin-rest-api/config"
+	"gin-rest-api/jobs"
 	"gin-rest-api/router"
+	"gin-rest-api/storage"
+	"gin-rest-api/utils"
 	"log"
 	"net/http"
 	"os"
@@ -20,6 +23,10 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if err := utils.InitValidator(); err != nil {
+		log.Fatal(err)
+	}
+
 	db, err := config.DBConnect(cfg)
 	if err != nil {
 		log.Fatal(err)
@@ -30,10 +37,22 @@ func main() {
 		log.Fatal(err)
 	}
 
+	store, err := storage.New(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	go jobs.Run(jobCtx, "publish posts", time.Duration(cfg.PublishInterval)*time.Second, jobs.PublishPosts(db, utils.NewFeed(cfg, db, rds)))
+	go jobs.Run(jobCtx, "flush views", time.Duration(cfg.ViewFlushInterval)*time.Second, jobs.FlushViews(db, utils.NewViews(cfg, rds)))
+	go jobs.Run(jobCtx, "clean attachments", time.Hour, jobs.CleanAttachments(db, store, time.Duration(cfg.UploadOrphanTTL)*time.Second))
+
 	r := gin.Default()
 
 	router.GetDocs(r)
-	router.GetRoute(r, cfg, db, rds)
+	router.GetRoute(r, cfg, db, rds, store)
 
 	srv := &http.Server{Addr: ":" + cfg.APPPort, Handler: r}
 
@@ -51,6 +70,8 @@ func main() {
 
 	log.Println("shutdown server...")
 
+	stopJobs()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -0,0 +1,68 @@
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var converter = goldmark.New(
+	goldmark.WithExtensions(
+		extension.Strikethrough,
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+	),
+)
+
+// policy allows the elements the converter produces and nothing else. Raw
+// HTML in the source is already dropped by the converter, the policy is the
+// second line of defense.
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+
+	p.AllowElements("p", "br", "hr", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote",
+		"pre", "code", "em", "strong", "del", "ul", "ol", "li", "table", "thead", "tbody", "tr")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+
+	p.AllowStandardURLs()
+	p.AllowAttrs("href", "title").OnElements("a")
+	p.AllowAttrs("src", "alt", "title").OnElements("img")
+	p.RequireNoFollowOnLinks(true)
+	p.RequireNoReferrerOnLinks(true)
+
+	return p
+}()
+
+var (
+	strict     = bluemonday.StrictPolicy()
+	blockEnd   = regexp.MustCompile(`</(p|h[1-6]|li|blockquote|pre|tr|th|td)>|<br\s*/?>|<hr\s*/?>`)
+	whitespace = regexp.MustCompile(`[ \t]+`)
+	blankLines = regexp.MustCompile(`\n{2,}`)
+)
+
+// Render converts CommonMark with GFM tables and strikethrough to sanitized
+// HTML, and to plain text for previews and search.
+func Render(source string) (htmlBody, textBody string) {
+	var buf bytes.Buffer
+
+	if err := converter.Convert([]byte(source), &buf); err != nil {
+		// the converter only fails on writer errors, which a buffer has none of
+		escaped := html.EscapeString(source)
+		return escaped, source
+	}
+
+	htmlBody = policy.Sanitize(buf.String())
+
+	text := blockEnd.ReplaceAllStringFunc(htmlBody, func(tag string) string { return tag + "\n" })
+	text = html.UnescapeString(strict.Sanitize(text))
+	text = whitespace.ReplaceAllString(text, " ")
+	text = blankLines.ReplaceAllString(text, "\n")
+
+	return htmlBody, strings.TrimSpace(text)
+}
@@ -0,0 +1,61 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name, source string
+		html, text   []string
+		not          []string
+	}{
+		{
+			name:   "raw html",
+			source: "hi <script>alert(1)</script> <img src=x onerror=alert(1)>",
+			html:   []string{"<p>hi"},
+			not:    []string{"<script", "onerror", "alert(1)</script>"},
+		},
+		{
+			name:   "javascript link",
+			source: "[click](javascript:alert(1)) [ok](https://example.com)",
+			html:   []string{`<a href="https://example.com" rel="nofollow noreferrer">ok</a>`},
+			not:    []string{"javascript:"},
+		},
+		{
+			name:   "table",
+			source: "| a | b |\n|:--|--:|\n| 1 | 2 |",
+			html:   []string{"<table>", `<th align="left">a</th>`, `<td align="right">2</td>`},
+			text:   []string{"a", "2"},
+		},
+		{
+			name:   "strikethrough and code",
+			source: "~~old~~ new\n\n```go\nx := 1 < 2\n```",
+			html:   []string{"<del>old</del>", `<code class="language-go">x := 1 &lt; 2`},
+			text:   []string{"old new", "x := 1 < 2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			html, text := Render(test.source)
+
+			for _, want := range test.html {
+				if !strings.Contains(html, want) {
+					t.Fatalf("%q is missing from %s", want, html)
+				}
+			}
+			for _, want := range test.text {
+				if !strings.Contains(text, want) {
+					t.Fatalf("%q is missing from %q", want, text)
+				}
+			}
+			for _, unwanted := range test.not {
+				if strings.Contains(html, unwanted) || strings.Contains(text, unwanted) {
+					t.Fatalf("%q was kept in %s / %q", unwanted, html, text)
+				}
+			}
+		})
+	}
+}
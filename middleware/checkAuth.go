@@ -10,15 +10,17 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
 type Middleware struct {
 	cfg *config.Config
+	db  *gorm.DB
 	rds *redis.Client
 }
 
-func NewMiddleware(cfg *config.Config, rds *redis.Client) *Middleware {
-	return &Middleware{cfg, rds}
+func NewMiddleware(cfg *config.Config, db *gorm.DB, rds *redis.Client) *Middleware {
+	return &Middleware{cfg, db, rds}
 }
 
 func (m *Middleware) CheckAuth(c *gin.Context) {
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"gin-rest-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CheckRole only lets users with one of the roles through. It runs after
+// CheckAuth.
+func (m *Middleware) CheckRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(roles, utils.GetUserRole(c, m.db)) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "you are not allowed to access this resource"})
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		c.Next()
+	}
+}
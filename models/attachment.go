@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Attachment is a file uploaded by a user, optionally linked to a post. Key
+// and ThumbnailKey locate the file and the thumbnail of an image in the
+// storage.
+type Attachment struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"`
+	PostID       *uint     `gorm:"index" json:"post_id"`
+	Key          string    `gorm:"not null;uniqueIndex" json:"-"`
+	ThumbnailKey string    `json:"-"`
+	Name         string    `gorm:"not null" json:"name"`
+	ContentType  string    `gorm:"type:varchar(100);not null" json:"content_type"`
+	Size         int64     `gorm:"not null" json:"size"`
+	Width        int       `json:"width,omitempty"`
+	Height       int       `json:"height,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Keys returns the storage keys of the file and of its thumbnail, if any.
+func (a Attachment) Keys() []string {
+	return []string{a.Key, a.ThumbnailKey}
+}
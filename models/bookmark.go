@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type Bookmark struct {
+	ID           uint                `gorm:"primaryKey" json:"id"`
+	UserID       uint                `gorm:"not null;uniqueIndex:idx_bookmarks_post" json:"user_id"`
+	PostID       uint                `gorm:"not null;uniqueIndex:idx_bookmarks_post" json:"post_id"`
+	CollectionID *uint               `gorm:"index" json:"collection_id"`
+	CreatedAt    time.Time           `json:"created_at"`
+	Post         Post                `json:"post"`
+	Collection   *BookmarkCollection `json:"collection"`
+}
+
+// BookmarkCollection is a named reading list of a user's bookmarks.
+type BookmarkCollection struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_bookmark_collections_name" json:"user_id"`
+	Name      string    `gorm:"not null;uniqueIndex:idx_bookmark_collections_name" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
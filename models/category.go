@@ -1,18 +1,45 @@
 package models
 
-import (
-	"github.com/gosimple/slug"
-	"gorm.io/gorm"
-)
+import "gorm.io/gorm"
 
 type Category struct {
 	gorm.Model
-	Name  string `gorm:"unique;not null" json:"name"`
-	Slug  string `gorm:"unique;not null" json:"slug"`
-	Posts []Post
+	// Name is unique among the categories that are not trashed.
+	Name     string `gorm:"not null;uniqueIndex:idx_categories_name,where:deleted_at IS NULL" json:"name"`
+	Slug     string `gorm:"unique;not null" json:"slug"`
+	Path     string `gorm:"unique;not null" json:"path"`
+	ParentID *uint  `gorm:"index" json:"parent_id"`
+	// RequireCommentApproval holds new comments on the category's posts for
+	// moderation.
+	RequireCommentApproval bool       `gorm:"not null;default:false" json:"require_comment_approval"`
+	Parent                 *Category  `gorm:"foreignkey:ParentID"`
+	Children               []Category `gorm:"foreignkey:ParentID"`
+	Posts                  []Post
+}
+
+// CategoryNode is a category in the category tree.
+type CategoryNode struct {
+	ID       uint            `json:"id"`
+	Name     string          `json:"name"`
+	Slug     string          `json:"slug"`
+	Path     string          `json:"path"`
+	Children []*CategoryNode `json:"children"`
 }
 
 func (category *Category) BeforeCreate(tx *gorm.DB) (err error) {
-	category.Slug = slug.Make(category.Name)
+	category.Slug, err = UniqueSlug(tx, "categories", category.Name, 0)
+	if err != nil {
+		return
+	}
+
+	category.Path = category.Slug
+	if category.ParentID != nil {
+		var parent Category
+		if err = tx.Session(&gorm.Session{NewDB: true}).First(&parent, *category.ParentID).Error; err != nil {
+			return
+		}
+		category.Path = parent.Path + "/" + category.Slug
+	}
+
 	return
 }
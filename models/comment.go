@@ -1,15 +1,61 @@
 package models
 
 import (
+	"fmt"
+	"gin-rest-api/markdown"
 	"time"
+
+	"gorm.io/gorm"
+)
+
+type CommentStatus string
+
+const (
+	CommentPending  CommentStatus = "pending"
+	CommentApproved CommentStatus = "approved"
+	CommentRejected CommentStatus = "rejected"
+	CommentSpam     CommentStatus = "spam"
 )
 
+// DeletedCommentBody replaces the body of a deleted comment that still has
+// replies, so that the thread keeps its structure.
+const DeletedCommentBody = "[deleted]"
+
 type Comment struct {
-	ID        uint   `gorm:"primaryKey"`
-	PostID    uint   `gorm:"foreignkey:PostID" json:"postID" binding:"required,gt=0"`
-	UserID    uint   `gorm:"foreignkey:UserID"`
-	Body      string `gorm:"type:text"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	User      User
+	ID             uint           `gorm:"primaryKey"`
+	PostID         uint           `gorm:"foreignkey:PostID" json:"postID" binding:"required,gt=0"`
+	ParentID       *uint          `gorm:"index" json:"parentID"`
+	UserID         uint           `gorm:"foreignkey:UserID"`
+	Body           string         `gorm:"type:text"`
+	BodyHTML       string         `gorm:"type:text;not null;default:''" json:"body_html"`
+	BodyText       string         `gorm:"type:text;not null;default:''" json:"body_text"`
+	Depth          int            `gorm:"not null;default:0"`
+	Path           string         `gorm:"index"`
+	ReplyCount     int            `gorm:"not null;default:0"`
+	Deleted        bool           `gorm:"not null;default:false"`
+	Status         CommentStatus  `gorm:"type:varchar(20);not null;default:approved;index"`
+	ReactionCounts ReactionCounts `gorm:"type:jsonb;not null;default:'{}'"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+	User           User
+}
+
+// CommentNode is a comment in a comment thread.
+type CommentNode struct {
+	Comment
+	Replies []*CommentNode
+}
+
+func (comment *Comment) BeforeCreate(tx *gorm.DB) error {
+	comment.BodyHTML, comment.BodyText = markdown.Render(comment.Body)
+	return nil
+}
+
+// AfterCreate appends the comment's zero padded id to Path, which holds the
+// path of its parent followed by a slash for replies. Ordering by path lists
+// a thread depth first.
+func (comment *Comment) AfterCreate(tx *gorm.DB) error {
+	comment.Path = fmt.Sprintf("%s%010d", comment.Path, comment.ID)
+	return tx.Model(comment).UpdateColumn("path", comment.Path).Error
 }
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+const (
+	FollowUsers      = "users"
+	FollowCategories = "categories"
+)
+
+// Follow is a user following an author or a category. TargetType is the
+// table of the target.
+type Follow struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	FollowerID uint      `gorm:"not null;uniqueIndex:idx_follows_unique,priority:1" json:"follower_id"`
+	TargetType string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_follows_unique,priority:2;index:idx_follows_target,priority:1" json:"target_type"`
+	TargetID   uint      `gorm:"not null;uniqueIndex:idx_follows_unique,priority:3;index:idx_follows_target,priority:2" json:"target_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -1,14 +1,67 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"gin-rest-api/markdown"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PostStatus string
+
+const (
+	PostDraft     PostStatus = "draft"
+	PostInReview  PostStatus = "in_review"
+	PostPublished PostStatus = "published"
+	PostScheduled PostStatus = "scheduled"
+	PostArchived  PostStatus = "archived"
+)
+
+// postTransitions lists the statuses a post may move to from each status.
+var postTransitions = map[PostStatus][]PostStatus{
+	PostDraft:     {PostInReview, PostPublished, PostScheduled, PostArchived},
+	PostInReview:  {PostDraft, PostPublished, PostScheduled, PostArchived},
+	PostScheduled: {PostDraft, PostPublished, PostArchived},
+	PostPublished: {PostDraft, PostArchived},
+	PostArchived:  {PostDraft},
+}
+
+func (s PostStatus) CanTransition(to PostStatus) bool {
+	return slices.Contains(postTransitions[s], to)
+}
 
 type Post struct {
 	gorm.Model
-	Title      string   `gorm:"not null" json:"title"`
-	Body       string   `gorm:"type:text" json:"body"`
-	CategoryID uint     `gorm:"foreignkey:CategoryID" json:"categoryID"`
-	UserID     uint     `gorm:"foreignkey:UserID" json:"userID"`
-	Category   Category `gorm:"foreignkey:CategoryID"`
-	User       User     `gorm:"foreignkey:UserID"`
-	Comments   []Comment
+	Title          string         `gorm:"not null" json:"title"`
+	Slug           string         `gorm:"unique;not null" json:"slug"`
+	Body           string         `gorm:"type:text" json:"body"`
+	BodyHTML       string         `gorm:"type:text;not null;default:''" json:"body_html"`
+	BodyText       string         `gorm:"type:text;not null;default:''" json:"body_text"`
+	Status         PostStatus     `gorm:"type:varchar(20);not null;default:draft;index" json:"status"`
+	PublishedAt    *time.Time     `json:"published_at"`
+	PublishAt      *time.Time     `gorm:"index" json:"publish_at"`
+	ExpireAt       *time.Time     `gorm:"index" json:"expire_at"`
+	ReactionCounts ReactionCounts `gorm:"type:jsonb;not null;default:'{}'" json:"reaction_counts"`
+	ViewCount      int64          `gorm:"not null;default:0" json:"view_count"`
+	CategoryID     uint           `gorm:"foreignkey:CategoryID" json:"categoryID"`
+	UserID         uint           `gorm:"foreignkey:UserID" json:"userID"`
+	Category       Category       `gorm:"foreignkey:CategoryID"`
+	User           User           `gorm:"foreignkey:UserID"`
+	Comments       []Comment
+	Tags           []Tag `gorm:"many2many:post_tags"`
+	Bookmarked     bool  `gorm:"-" json:"bookmarked"`
+}
+
+func (post *Post) BeforeCreate(tx *gorm.DB) (err error) {
+	post.BodyHTML, post.BodyText = markdown.Render(post.Body)
+	post.Slug, err = UniqueSlug(tx, "posts", post.Title, 0)
+	return
+}
+
+// RenderBody returns the columns of a Markdown body and of its rendered
+// copies, for updates that write the body of a post or a comment.
+func RenderBody(body string) map[string]any {
+	html, text := markdown.Render(body)
+	return map[string]any{"body": body, "body_html": html, "body_text": text}
 }
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Reaction is a user's reaction of one type to a post or a comment.
+// TargetType is the table of the target.
+type Reaction struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	TargetType string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_reactions_unique,priority:1" json:"target_type"`
+	TargetID   uint      `gorm:"not null;uniqueIndex:idx_reactions_unique,priority:2" json:"target_id"`
+	Type       string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_reactions_unique,priority:3" json:"type"`
+	UserID     uint      `gorm:"not null;uniqueIndex:idx_reactions_unique,priority:4" json:"user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	User       User      `json:"user"`
+}
+
+// ReactionCounts is the number of reactions of each type, stored as jsonb.
+type ReactionCounts map[string]int64
+
+func (r ReactionCounts) Value() (driver.Value, error) {
+	if r == nil {
+		return "{}", nil
+	}
+
+	data, err := json.Marshal(r)
+	return string(data), err
+}
+
+func (r *ReactionCounts) Scan(value any) error {
+	var data []byte
+
+	switch v := value.(type) {
+	case nil:
+		*r = ReactionCounts{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported reaction counts value")
+	}
+
+	return json.Unmarshal(data, r)
+}
@@ -1,36 +1,121 @@
 package models
 
+import "time"
+
 type RegisterRequest struct {
-	Name     string `json:"name" binding:"required,min=2,max=50"`
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Name     string `json:"name" xml:"name" yaml:"name" binding:"required,min=2,max=50"`
+	Username string `json:"username" xml:"username" yaml:"username" binding:"required,min=3,max=30,username"`
+	Email    string `json:"email" xml:"email" yaml:"email" binding:"required,email"`
+	Password string `json:"password" xml:"password" yaml:"password" binding:"required,min=6"`
 }
 
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email    string `json:"email" xml:"email" yaml:"email" binding:"required,email"`
+	Password string `json:"password" xml:"password" yaml:"password" binding:"required"`
 }
 
 type UserUpdateRequest struct {
-	Name  string `json:"name" binding:"required,min=2,max=50"`
-	Email string `json:"email" binding:"required,email"`
+	Name  string `json:"name" xml:"name" yaml:"name" binding:"required,min=2,max=50"`
+	Email string `json:"email" xml:"email" yaml:"email" binding:"required,email"`
+}
+
+type ProfileRequest struct {
+	Username string `json:"username" xml:"username" yaml:"username" binding:"required,min=3,max=30,username"`
+	Bio      string `json:"bio" xml:"bio" yaml:"bio" binding:"max=500"`
+	Website  string `json:"website" xml:"website" yaml:"website" binding:"omitempty,http_url,max=200"`
+}
+
+type AvatarRequest struct {
+	AttachmentId *uint `json:"attachmentId" xml:"attachmentId" yaml:"attachmentId" binding:"omitempty,min=1"`
+}
+
+type UserRoleRequest struct {
+	Role string `json:"role" xml:"role" yaml:"role" binding:"required,oneof=user moderator admin"`
 }
 
 type PostRequest struct {
-	Title      string `json:"title" binding:"required,min=2,max=200"`
-	Body       string `json:"body" binding:"required"`
-	CategoryId uint   `json:"categoryId" binding:"required,min=1"`
+	Title      string   `json:"title" xml:"title" yaml:"title" binding:"required,min=2,max=200"`
+	Body       string   `json:"body" xml:"body" yaml:"body" binding:"required"`
+	CategoryId uint     `json:"categoryId" xml:"categoryId" yaml:"categoryId" binding:"required,min=1"`
+	Tags       []string `json:"tags" xml:"tags" yaml:"tags" binding:"omitempty,max=10,dive,min=1,max=50"`
+}
+
+type RevisionDiffRequest struct {
+	From int    `form:"from" json:"from" binding:"required,min=1"`
+	To   int    `form:"to" json:"to" binding:"required,min=1"`
+	Mode string `form:"mode" json:"mode" binding:"omitempty,oneof=unified word"`
+}
+
+type AttachmentRequest struct {
+	PostId *uint `form:"postId" json:"postId" binding:"omitempty,min=1"`
+}
+
+type CategoryAddRequest struct {
+	Name     string `json:"name" xml:"name" yaml:"name" binding:"required,min=2"`
+	ParentId *uint  `json:"parentId" xml:"parentId" yaml:"parentId" binding:"omitempty,min=1"`
 }
 
 type CategoryRequest struct {
-	Name string `json:"name" binding:"required,min=2"`
+	Name string `json:"name" xml:"name" yaml:"name" binding:"required,min=2"`
+}
+
+type CategoryModerationRequest struct {
+	RequireApproval *bool `json:"requireApproval" xml:"requireApproval" yaml:"requireApproval" binding:"required"`
+}
+
+type CategoryMoveRequest struct {
+	ParentId *uint `json:"parentId" xml:"parentId" yaml:"parentId" binding:"omitempty,min=1"`
+}
+
+type TagRequest struct {
+	Name string `json:"name" xml:"name" yaml:"name" binding:"required,min=1,max=50"`
+}
+
+type TagMergeRequest struct {
+	TargetId uint `json:"targetId" xml:"targetId" yaml:"targetId" binding:"required,min=1"`
 }
 
 type CommentAddRequest struct {
-	PostId uint   `json:"postId" binding:"required,min=1"`
-	Body   string `json:"body" binding:"required,min=1"`
+	PostId uint   `json:"postId" xml:"postId" yaml:"postId" binding:"required,min=1"`
+	Body   string `json:"body" xml:"body" yaml:"body" binding:"required,min=1"`
+}
+
+type BookmarkRequest struct {
+	CollectionId *uint `json:"collectionId" xml:"collectionId" yaml:"collectionId" binding:"omitempty,min=1"`
+}
+
+type BookmarkCollectionRequest struct {
+	Name string `json:"name" xml:"name" yaml:"name" binding:"required,min=1,max=100"`
+}
+
+type ReactionRequest struct {
+	Type string `json:"type" xml:"type" yaml:"type" binding:"required"`
+}
+
+type CommentModerationRequest struct {
+	Ids    []uint `json:"ids" xml:"ids" yaml:"ids" binding:"required,min=1,max=100,dive,min=1"`
+	Action string `json:"action" xml:"action" yaml:"action" binding:"required,oneof=approve reject spam"`
+}
+
+type CommentReplyRequest struct {
+	Body string `json:"body" xml:"body" yaml:"body" binding:"required,min=1"`
 }
 
 type CommentEditRequest struct {
-	Body string `json:"body" binding:"required,min=1"`
+	Body string `json:"body" xml:"body" yaml:"body" binding:"required,min=1"`
+}
+
+type PostStatusRequest struct {
+	Status    string     `json:"status" xml:"status" yaml:"status" binding:"required,oneof=draft in_review published scheduled archived"`
+	PublishAt *time.Time `json:"publishAt" xml:"publishAt" yaml:"publishAt" binding:"required_if=Status scheduled"`
+	ExpireAt  *time.Time `json:"expireAt" xml:"expireAt" yaml:"expireAt"`
+}
+
+type RestoreRequest struct {
+	Ids []uint `json:"ids" xml:"ids" yaml:"ids" binding:"required,min=1,max=100,dive,min=1"`
+}
+
+type PostViewsRequest struct {
+	From string `form:"from" json:"from" binding:"omitempty,datetime=2006-01-02"`
+	To   string `form:"to" json:"to" binding:"omitempty,datetime=2006-01-02"`
 }
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostRevision is an immutable copy of a post as it was after a change,
+// numbered from 1 per post.
+type PostRevision struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	PostID     uint      `gorm:"not null;uniqueIndex:idx_post_revisions_number,priority:1" json:"post_id"`
+	Number     int       `gorm:"not null;uniqueIndex:idx_post_revisions_number,priority:2" json:"number"`
+	UserID     uint      `gorm:"not null" json:"user_id"`
+	Title      string    `gorm:"not null" json:"title"`
+	Body       string    `gorm:"not null" json:"body"`
+	CategoryID uint      `gorm:"not null" json:"category_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	User       User      `json:"user"`
+}
+
+// AddRevision records revision as the next revision of its post and drops
+// the revisions older than the newest limit ones. A limit of 0 keeps them all.
+func AddRevision(tx *gorm.DB, revision *PostRevision, limit int) error {
+	// concurrent edits of the post take their numbers in turn
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+		Where("id = ?", revision.PostID).Take(&Post{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Model(&PostRevision{}).Where("post_id = ?", revision.PostID).
+		Select("COALESCE(MAX(number), 0) + 1").Scan(&revision.Number).Error
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Create(revision).Error; err != nil {
+		return err
+	}
+
+	if limit <= 0 {
+		return nil
+	}
+
+	return tx.Where("post_id = ? AND number <= ?", revision.PostID, revision.Number-limit).Delete(&PostRevision{}).Error
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestAddRevisionLocksPost(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery(`SELECT "id" FROM "posts" WHERE id = \$1 AND "posts"."deleted_at" IS NULL LIMIT \$2 FOR UPDATE`).
+		WithArgs(5, 1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(number\), 0\) \+ 1 FROM "post_revisions" WHERE post_id = \$1`).
+		WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"number"}).AddRow(4))
+	mock.ExpectQuery(`INSERT INTO "post_revisions"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(`DELETE FROM "post_revisions" WHERE post_id = \$1 AND number <= \$2`).
+		WithArgs(5, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	revision := PostRevision{PostID: 5, UserID: 7, Title: "t"}
+	if err := AddRevision(db, &revision, 3); err != nil {
+		t.Fatal(err)
+	}
+	if revision.Number != 4 {
+		t.Fatalf("got number %d, want 4", revision.Number)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
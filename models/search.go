@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+type SearchRequest struct {
+	Q          string `form:"q" json:"q" binding:"required,min=2"`
+	Type       string `form:"type" json:"type" binding:"omitempty,oneof=posts comments"`
+	CategoryId uint   `form:"categoryId" json:"categoryId"`
+	UserId     uint   `form:"userId" json:"userId"`
+}
+
+type SearchResult struct {
+	Type      string    `json:"type"`
+	ID        uint      `json:"id"`
+	PostID    uint      `json:"post_id"`
+	Title     string    `json:"title"`
+	Snippet   string    `json:"snippet"`
+	Rank      float64   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gosimple/slug"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// SlugHistory keeps the previous slugs of posts and categories so that old
+// URLs still resolve. Resource is the table of the record.
+type SlugHistory struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Resource  string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_slug_histories_slug" json:"resource"`
+	Slug      string    `gorm:"not null;uniqueIndex:idx_slug_histories_slug" json:"slug"`
+	RecordID  uint      `gorm:"not null;index" json:"record_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// slugEscaper escapes the LIKE wildcards a slug may contain.
+var slugEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// slugRetries bounds how many times CreateWithSlug picks another slug.
+const slugRetries = 5
+
+// UniqueSlug makes a slug of source that no other record of table uses, now
+// or in its history, by appending -2, -3, ... on collisions.
+func UniqueSlug(tx *gorm.DB, table, source string, id uint) (string, error) {
+	base := slug.Make(source)
+	if base == "" {
+		base = "untitled"
+	}
+
+	db := tx.Session(&gorm.Session{NewDB: true})
+	pattern := slugEscaper.Replace(base) + "-%"
+
+	var taken, previous []string
+
+	err := db.Table(table).
+		Where("(slug = ? OR slug LIKE ?) AND id <> ?", base, pattern, id).
+		Pluck("slug", &taken).Error
+	if err != nil {
+		return "", err
+	}
+
+	err = db.Model(&SlugHistory{}).
+		Where("resource = ? AND (slug = ? OR slug LIKE ?) AND record_id <> ?", table, base, pattern, id).
+		Pluck("slug", &previous).Error
+	if err != nil {
+		return "", err
+	}
+
+	taken = append(taken, previous...)
+
+	candidate := base
+	for n := 2; slices.Contains(taken, candidate); n++ {
+		candidate = fmt.Sprintf("%s-%d", base, n)
+	}
+
+	return candidate, nil
+}
+
+// CreateWithSlug creates value, whose BeforeCreate hook picks its slug with
+// UniqueSlug. A record created concurrently may take the same slug between
+// the check and the insert, so the insert is retried with a fresh slug.
+func CreateWithSlug(tx *gorm.DB, value any) (err error) {
+	for range slugRetries {
+		// a savepoint keeps the outer transaction usable after a violation
+		err = tx.Transaction(func(tx *gorm.DB) error {
+			return tx.Create(value).Error
+		})
+		if !isSlugConflict(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func isSlugConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && strings.HasSuffix(pgErr.ConstraintName, "_slug")
+}
+
+// RenameSlug returns the slug of the renamed record and moves its current
+// slug to the history when it changes.
+func RenameSlug(tx *gorm.DB, table string, id uint, current, source string) (string, error) {
+	next, err := UniqueSlug(tx, table, source, id)
+	if err != nil || next == current {
+		return next, err
+	}
+
+	// renaming back to an old slug takes it out of the history
+	err = tx.Where("resource = ? AND slug = ?", table, next).Delete(&SlugHistory{}).Error
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Create(&SlugHistory{Resource: table, Slug: current, RecordID: id}).Error
+	return next, err
+}
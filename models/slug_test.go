@@ -0,0 +1,65 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestUniqueSlugEscapesPattern(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}), &gorm.Config{
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sql []string
+	db.Callback().Query().After("gorm:query").Register("test:sql", func(tx *gorm.DB) {
+		sql = append(sql, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	})
+
+	got, err := UniqueSlug(db, "posts", "snake_case 100%", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "snake_case-100" {
+		t.Fatalf("got slug %q", got)
+	}
+
+	if len(sql) != 2 {
+		t.Fatalf("got %d queries", len(sql))
+	}
+	for _, query := range sql {
+		if !strings.Contains(query, `LIKE 'snake\_case-100-%'`) {
+			t.Fatalf("the pattern is not escaped in %s", query)
+		}
+	}
+}
+
+func TestIsSlugConflict(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&pgconn.PgError{Code: "23505", ConstraintName: "uni_posts_slug"}, true},
+		{fmt.Errorf("create: %w", &pgconn.PgError{Code: "23505", ConstraintName: "uni_categories_slug"}), true},
+		{&pgconn.PgError{Code: "23505", ConstraintName: "idx_categories_name"}, false},
+		{&pgconn.PgError{Code: "23503", ConstraintName: "fk_posts_slug"}, false},
+		{errors.New("duplicate key value violates unique constraint \"uni_posts_slug\""), false},
+		{nil, false},
+	}
+
+	for _, test := range tests {
+		if got := isSlugConflict(test.err); got != test.want {
+			t.Fatalf("isSlugConflict(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gosimple/slug"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"not null" json:"name"`
+	Slug      string    `gorm:"unique;not null" json:"slug"`
+	PostCount int64     `gorm:"->;-:migration" json:"post_count"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Posts     []Post    `gorm:"many2many:post_tags" json:"-"`
+}
+
+// FindOrCreateTags returns the tags with the given names and creates the
+// unknown ones. Names with the same slug are the same tag.
+func FindOrCreateTags(tx *gorm.DB, names []string) ([]Tag, error) {
+	tags := []Tag{}
+	slugs := []string{}
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		tagSlug := slug.Make(name)
+		if tagSlug == "" || slices.Contains(slugs, tagSlug) {
+			continue
+		}
+
+		slugs = append(slugs, tagSlug)
+		tags = append(tags, Tag{Name: name, Slug: tagSlug})
+	}
+
+	if len(tags) == 0 {
+		return tags, nil
+	}
+
+	err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "slug"}}, DoNothing: true}).
+		Create(&tags).Error
+	if err != nil {
+		return nil, err
+	}
+
+	found := []Tag{}
+	err = tx.Where("slug IN ?", slugs).Find(&found).Error
+	return found, err
+}
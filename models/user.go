@@ -1,11 +1,49 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
 
+	"gorm.io/gorm"
+)
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// User is an account. Usernames and emails are unique among the users that
+// are not trashed, so they can be taken again until a trashed user is
+// restored.
 type User struct {
 	gorm.Model
 	Name     string `json:"name"`
-	Email    string `json:"email" gorm:"unique;not null"`
+	Username string `json:"username" gorm:"type:varchar(30);not null;uniqueIndex:idx_users_username,where:deleted_at IS NULL"`
+	Email    string `json:"email" gorm:"not null;uniqueIndex:idx_users_email,where:deleted_at IS NULL"`
 	Password string `json:"-"`
+	Role     string `json:"role" gorm:"type:varchar(20);not null;default:user"`
+	Bio      string `json:"bio" gorm:"type:varchar(500)"`
+	Website  string `json:"website"`
+	AvatarID *uint  `json:"avatar_id"`
 	Posts    []Post `swaggerignore:"true"`
 }
+
+// Profile is the public face of a user, without the private attributes.
+type Profile struct {
+	ID        uint         `json:"id"`
+	Username  string       `json:"username"`
+	Name      string       `json:"name"`
+	Bio       string       `json:"bio"`
+	Website   string       `json:"website"`
+	AvatarURL string       `json:"avatar_url"`
+	JoinedAt  time.Time    `json:"joined_at"`
+	Stats     ProfileStats `json:"stats"`
+}
+
+// ProfileStats counts the public activity of a user.
+type ProfileStats struct {
+	Posts     int64 `json:"posts"`
+	Comments  int64 `json:"comments"`
+	Followers int64 `json:"followers"`
+	Following int64 `json:"following"`
+}
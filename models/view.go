@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// PostDailyView is the number of views of a post on a day, in UTC.
+type PostDailyView struct {
+	PostID uint      `gorm:"primaryKey;autoIncrement:false" json:"post_id"`
+	Date   time.Time `gorm:"primaryKey;type:date" json:"date"`
+	Views  int64     `gorm:"not null;default:0" json:"views"`
+}
+
+// DailyViews is a day of the views of a post.
+type DailyViews struct {
+	Date  string `json:"date"`
+	Views int64  `json:"views"`
+}
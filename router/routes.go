@@ -4,34 +4,58 @@ import (
 	"gin-rest-api/config"
 	"gin-rest-api/controllers"
 	"gin-rest-api/middleware"
+	"gin-rest-api/models"
+	"gin-rest-api/storage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
-func GetRoute(r *gin.Engine, cfg *config.Config, db *gorm.DB, rds *redis.Client) {
-	middle := middleware.NewMiddleware(cfg, rds)
+func GetRoute(r *gin.Engine, cfg *config.Config, db *gorm.DB, rds *redis.Client, store storage.Storage) {
+	middle := middleware.NewMiddleware(cfg, db, rds)
 	user := controllers.NewUserAPI(cfg, db, rds)
-	category := controllers.NewCategoryAPI(db)
-	post := controllers.NewPostAPI(db)
-	comment := controllers.NewCommentAPI(db)
+	category := controllers.NewCategoryAPI(cfg, db)
+	post := controllers.NewPostAPI(cfg, db, rds, store)
+	comment := controllers.NewCommentAPI(cfg, db)
+	tag := controllers.NewTagAPI(cfg, db)
+	reaction := controllers.NewReactionAPI(cfg, db)
+	bookmark := controllers.NewBookmarkAPI(cfg, db)
+	follow := controllers.NewFollowAPI(cfg, db, rds)
+	revision := controllers.NewRevisionAPI(cfg, db)
+	attachment := controllers.NewAttachmentAPI(cfg, db, store)
+	profile := controllers.NewProfileAPI(cfg, db, store)
+	search := controllers.NewSearchAPI(cfg, db)
 
 	// User routes
 	r.POST("/api/register", user.Register)
 	r.POST("/api/login", user.Login)
 
+	// Public profile routes
+	r.GET("/api/profiles/:username", profile.Get)
+	r.GET("/api/profiles/:username/avatar", profile.Avatar)
+
 	r.Use(middle.CheckAuth)
 	r.GET("/api/me", user.Me)
 	r.POST("/api/logout", user.Logout)
+	r.PUT("/api/profile", profile.Update)
+	r.PUT("/api/profile/avatar", profile.SetAvatar)
 	userRouter := r.Group("/api/users")
 	{
 		userRouter.GET("/", user.Gets)
 		userRouter.GET("/:id/show", user.Get)
 		userRouter.PUT("/:id/update", user.Update)
+		userRouter.PUT("/:id/role", middle.CheckRole(models.RoleAdmin), user.UpdateRole)
+		userRouter.POST("/:id/follow", follow.FollowUser)
+		userRouter.DELETE("/:id/follow", follow.UnfollowUser)
+		userRouter.GET("/:id/followers", follow.Followers)
+		userRouter.GET("/:id/following", follow.Following)
+		userRouter.GET("/:id/following-categories", follow.FollowingCategories)
 		userRouter.DELETE("/:id/delete", user.Delete)
-		userRouter.GET("/all-trash", user.Trashed)
-		userRouter.DELETE("/:id/delete-trash", user.EmptyTrash)
+		userRouter.GET("/all-trash", middle.CheckRole(models.RoleAdmin), user.Trashed)
+		userRouter.DELETE("/:id/delete-trash", middle.CheckRole(models.RoleAdmin), user.EmptyTrash)
+		userRouter.POST("/:id/restore", middle.CheckRole(models.RoleAdmin), user.Restore)
+		userRouter.POST("/restore", middle.CheckRole(models.RoleAdmin), user.RestoreMany)
 	}
 
 	// Category routes
@@ -40,10 +64,22 @@ func GetRoute(r *gin.Engine, cfg *config.Config, db *gorm.DB, rds *redis.Client)
 		catRouter.GET("/", category.Gets)
 		catRouter.POST("/create", category.Create)
 		catRouter.GET("/:id/show", category.Get)
+		catRouter.GET("/by-slug/:slug", category.GetBySlug)
+		catRouter.GET("/by-path/*path", category.GetByPath)
+		catRouter.GET("/tree", category.Tree)
+		catRouter.GET("/:id/breadcrumb", category.Breadcrumb)
+		catRouter.GET("/:id/posts", post.GetsByCategory)
+		catRouter.POST("/:id/follow", follow.FollowCategory)
+		catRouter.DELETE("/:id/follow", follow.UnfollowCategory)
+		catRouter.GET("/:id/followers", follow.CategoryFollowers)
+		catRouter.PUT("/:id/move", middle.CheckRole(models.RoleModerator, models.RoleAdmin), category.Move)
+		catRouter.PUT("/:id/moderation", middle.CheckRole(models.RoleModerator, models.RoleAdmin), category.SetModeration)
 		catRouter.PUT("/:id/update", category.Update)
-		catRouter.DELETE("/:id/delete", category.Delete)
+		catRouter.DELETE("/:id/delete", middle.CheckRole(models.RoleModerator, models.RoleAdmin), category.Delete)
 		catRouter.GET("/all-trash", category.Trashed)
-		catRouter.DELETE("/:id/delete-trash", category.EmptyTrash)
+		catRouter.DELETE("/:id/delete-trash", middle.CheckRole(models.RoleModerator, models.RoleAdmin), category.EmptyTrash)
+		catRouter.POST("/:id/restore", middle.CheckRole(models.RoleModerator, models.RoleAdmin), category.Restore)
+		catRouter.POST("/restore", middle.CheckRole(models.RoleModerator, models.RoleAdmin), category.RestoreMany)
 	}
 
 	// Post routes
@@ -52,18 +88,81 @@ func GetRoute(r *gin.Engine, cfg *config.Config, db *gorm.DB, rds *redis.Client)
 		postRouter.GET("/", post.Gets)
 		postRouter.POST("/create", post.Create)
 		postRouter.GET("/:id/show", post.Get)
+		postRouter.GET("/:id/comments", comment.Gets)
+		postRouter.GET("/:id/reactions", reaction.GetsPost)
+		postRouter.POST("/:id/reactions", reaction.TogglePost)
+		postRouter.POST("/:id/bookmark", bookmark.Add)
+		postRouter.DELETE("/:id/bookmark", bookmark.Remove)
+		postRouter.GET("/by-slug/:slug", post.GetBySlug)
 		postRouter.PUT("/:id/update", post.Update)
+		postRouter.PUT("/:id/status", post.Transition)
+		postRouter.GET("/:id/attachments", attachment.GetsPost)
+		postRouter.GET("/:id/views", post.Views)
+		postRouter.GET("/:id/revisions", revision.Gets)
+		postRouter.GET("/:id/revisions/diff", revision.Diff)
+		postRouter.GET("/:id/revisions/:number", revision.Get)
+		postRouter.POST("/:id/revisions/:number/restore", revision.Restore)
 		postRouter.DELETE("/:id/delete", post.Delete)
 		postRouter.GET("/all-trash", post.Trashed)
 		postRouter.DELETE("/:id/delete-trash", post.EmptyTrash)
+		postRouter.POST("/:id/restore", post.Restore)
+		postRouter.POST("/restore", post.RestoreMany)
 	}
 
 	// Comment routes
 	commentRouter := r.Group("/api/posts/:id/comment")
 	{
 		commentRouter.POST("/create", comment.Create)
+		commentRouter.GET("/thread", comment.Thread)
+		commentRouter.POST("/:comment_id/reply", comment.Reply)
+		commentRouter.GET("/:comment_id/thread", comment.Thread)
+		commentRouter.GET("/:comment_id/reactions", reaction.GetsComment)
+		commentRouter.POST("/:comment_id/reactions", reaction.ToggleComment)
 		commentRouter.GET("/:comment_id/show", comment.Get)
 		commentRouter.PUT("/:comment_id/update", comment.Update)
 		commentRouter.DELETE("/:comment_id/delete", comment.Delete)
+		commentRouter.GET("/all-trash", comment.Trashed)
+		commentRouter.DELETE("/:comment_id/delete-trash", comment.EmptyTrash)
+	}
+
+	// Moderation routes
+	modRouter := r.Group("/api/moderation", middle.CheckRole(models.RoleModerator, models.RoleAdmin))
+	{
+		modRouter.GET("/comments", comment.Queue)
+		modRouter.POST("/comments", comment.Moderate)
 	}
+
+	// Bookmark routes
+	bookmarkRouter := r.Group("/api/bookmarks")
+	{
+		bookmarkRouter.GET("/", bookmark.Gets)
+		bookmarkRouter.GET("/collections", bookmark.Collections)
+		bookmarkRouter.POST("/collections/create", bookmark.CreateCollection)
+		bookmarkRouter.PUT("/collections/:id/update", bookmark.UpdateCollection)
+		bookmarkRouter.DELETE("/collections/:id/delete", bookmark.DeleteCollection)
+	}
+
+	// Attachment routes
+	attachmentRouter := r.Group("/api/attachments")
+	{
+		attachmentRouter.GET("/", attachment.Gets)
+		attachmentRouter.POST("/upload", attachment.Upload)
+		attachmentRouter.GET("/:id/show", attachment.Get)
+		attachmentRouter.GET("/:id/file", attachment.File)
+		attachmentRouter.DELETE("/:id/delete", attachment.Delete)
+	}
+
+	// Tag routes
+	tagRouter := r.Group("/api/tags")
+	{
+		tagRouter.GET("/", tag.Gets)
+		tagRouter.PUT("/:id/update", middle.CheckRole(models.RoleModerator, models.RoleAdmin), tag.Update)
+		tagRouter.POST("/:id/merge", middle.CheckRole(models.RoleModerator, models.RoleAdmin), tag.Merge)
+	}
+
+	// Feed routes
+	r.GET("/api/feed", follow.Feed)
+
+	// Search routes
+	r.GET("/api/search", search.Search)
 }
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gin-rest-api/config"
+	"io"
+)
+
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage keeps uploaded files under slash separated keys.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// New returns the storage selected by STORAGE_DRIVER.
+func New(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageDriver {
+	case "local":
+		return NewLocal(cfg.StoragePath)
+	case "s3":
+		return NewS3(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3PathStyle)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
+
+// Remove deletes the objects of keys, skipping the empty ones. Failures are
+// ignored, a stray object only takes space.
+func Remove(ctx context.Context, s Storage, keys ...string) {
+	for _, key := range keys {
+		if key != "" {
+			s.Delete(ctx, key)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BodyFormat is the representation of Markdown bodies a client asked for
+// with `?body=raw|html|text`.
+type BodyFormat string
+
+const (
+	BodyAll  BodyFormat = ""
+	BodyRaw  BodyFormat = "raw"
+	BodyHTML BodyFormat = "html"
+	BodyText BodyFormat = "text"
+)
+
+// bodyKeys are the payload keys of every representation. Posts return the
+// raw body as "body" and comments as "Body".
+var bodyKeys = map[BodyFormat][]string{
+	BodyRaw:  {"body", "Body"},
+	BodyHTML: {"body_html"},
+	BodyText: {"body_text"},
+}
+
+// ParseBodyFormat parses the body query parameter. Without it only the raw
+// body is returned, unless the request picks its own fields.
+func ParseBodyFormat(c *gin.Context) (BodyFormat, error) {
+	param, ok := c.GetQuery("body")
+	if !ok {
+		if _, hasFields := c.GetQuery("fields"); hasFields {
+			return BodyAll, nil
+		}
+		return BodyRaw, nil
+	}
+
+	format := BodyFormat(param)
+	if _, ok := bodyKeys[format]; !ok {
+		return "", fmt.Errorf("body must be raw, html or text")
+	}
+
+	return format, nil
+}
+
+// Pick drops the other representations of the body from every record of
+// data, nested ones included, that has a rendered body.
+func (f BodyFormat) Pick(data any) (any, error) {
+	tree, err := toTree(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if f != BodyAll {
+		f.pickValue(tree)
+	}
+	return tree, nil
+}
+
+func (f BodyFormat) pickValue(value any) {
+	switch v := value.(type) {
+	case []any:
+		for _, item := range v {
+			f.pickValue(item)
+		}
+	case map[string]any:
+		_, hasHTML := v["body_html"]
+		_, hasText := v["body_text"]
+		if hasHTML || hasText {
+			for format, keys := range bodyKeys {
+				if format == f {
+					continue
+				}
+				for _, key := range keys {
+					delete(v, key)
+				}
+			}
+		}
+
+		for _, item := range v {
+			f.pickValue(item)
+		}
+	}
+}
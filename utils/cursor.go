@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin-rest-api/config"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor marks a position in a listing ordered by Sorts then by id, which
+// follows the direction of the first sort. Values are the sort keys of the
+// row at that position and ID its primary key. Before asks for the page
+// preceding that position.
+type cursor struct {
+	Sorts  []Sort `json:"s"`
+	Values []any  `json:"v"`
+	ID     any    `json:"id"`
+	Before bool   `json:"b,omitempty"`
+}
+
+func encodeCursor(cfg *config.Config, cur cursor) (string, error) {
+	payload, err := json.Marshal(cur)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + signCursor(cfg, encoded), nil
+}
+
+func decodeCursor(cfg *config.Config, token string) (cursor, error) {
+	var cur cursor
+
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(signCursor(cfg, encoded))) {
+		return cur, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cur, ErrInvalidCursor
+	}
+
+	// numbers are kept exact, JSON floats would round large ids
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&cur); err != nil || len(cur.Values) != len(cur.Sorts) || cur.ID == nil {
+		return cur, ErrInvalidCursor
+	}
+
+	for i, value := range cur.Values {
+		cur.Values[i] = jsonNumber(value)
+	}
+	cur.ID = jsonNumber(cur.ID)
+
+	return cur, nil
+}
+
+// jsonNumber converts a number decoded with UseNumber to the integer or float
+// it holds.
+func jsonNumber(value any) any {
+	number, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+	if n, err := number.Int64(); err == nil {
+		return n
+	}
+	if n, err := strconv.ParseUint(number.String(), 10, 64); err == nil {
+		return n
+	}
+	if f, err := number.Float64(); err == nil {
+		return f
+	}
+	return value
+}
+
+func signCursor(cfg *config.Config, encoded string) string {
+	mac := hmac.New(sha256.New, []byte("cursor:"+cfg.JWTSecret))
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// CursorPaginate returns up to params.PerPage rows ordered by the sort
+// columns and id, starting after the position encoded in params.Cursor. An
+// empty cursor returns the first page.
+func CursorPaginate[T any](db *gorm.DB, cfg *config.Config, params PageParams, scopes ...func(*gorm.DB) *gorm.DB) (Page[T], error) {
+	var cur cursor
+
+	sorts := params.List.Cursor()
+	if params.Cursor != "" {
+		var err error
+		if cur, err = decodeCursor(cfg, params.Cursor); err != nil || !slices.Equal(cur.Sorts, sorts) {
+			return Page[T]{}, ErrInvalidCursor
+		}
+	}
+
+	query := params.List.Scope(db)
+	for _, scope := range scopes {
+		query = scope(query)
+	}
+
+	// a restricted column list still needs the keys the cursors are built from
+	if selects := query.Statement.Selects; len(selects) > 0 {
+		columns := slices.Clone(selects)
+		for _, sort := range sorts {
+			columns = appendColumn(columns, sort.Column)
+		}
+		query = query.Select(appendColumn(columns, "id"))
+	}
+
+	// walking backwards flips both the comparisons and the order
+	if params.Cursor != "" {
+		condition, args := keysetAfter(sorts, cur.Values, cur.ID, cur.Before)
+		query = query.Where(condition, args...)
+	}
+
+	for _, sort := range sorts {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: sort.Column}, Desc: sort.Desc != cur.Before})
+	}
+
+	rows := []T{}
+	err := query.
+		Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: sorts[0].Desc != cur.Before}).
+		Limit(params.PerPage + 1).Find(&rows).Error
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	hasMore := len(rows) > params.PerPage
+	if hasMore {
+		rows = rows[:params.PerPage]
+	}
+	if cur.Before {
+		slices.Reverse(rows)
+	}
+
+	page := Page[T]{Data: rows, PerPage: params.PerPage, PageCursor: &PageCursor{}}
+	if len(rows) == 0 {
+		return page, nil
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&rows); err != nil {
+		return Page[T]{}, err
+	}
+
+	primary := stmt.Schema.PrioritizedPrimaryField
+	if primary == nil {
+		return Page[T]{}, fmt.Errorf("%s has no primary key to paginate on", stmt.Schema.Name)
+	}
+
+	fields := make([]*schema.Field, len(sorts))
+	for i, sort := range sorts {
+		if fields[i] = stmt.Schema.LookUpField(sort.Column); fields[i] == nil {
+			return Page[T]{}, fmt.Errorf("unknown cursor column %s", sort.Column)
+		}
+	}
+
+	position := func(row *T, before bool) (string, error) {
+		ctx, value := context.Background(), reflect.ValueOf(row).Elem()
+
+		values := make([]any, len(fields))
+		for i, field := range fields {
+			key, _ := field.ValueOf(ctx, value)
+			if values[i], err = keyValue(key); err != nil {
+				return "", err
+			}
+		}
+
+		id, _ := primary.ValueOf(ctx, value)
+		return encodeCursor(cfg, cursor{Sorts: sorts, Values: values, ID: id, Before: before})
+	}
+
+	if hasMore || cur.Before {
+		if page.Next, err = position(&rows[len(rows)-1], false); err != nil {
+			return Page[T]{}, err
+		}
+	}
+	if params.Cursor != "" && (hasMore || !cur.Before) {
+		if page.Prev, err = position(&rows[0], true); err != nil {
+			return Page[T]{}, err
+		}
+	}
+
+	return page, nil
+}
+
+// keysetAfter builds the condition for the rows that come after a position
+// in the order of sorts then id, or before it when flip is set. NULL comes
+// after every value, as it does in Postgres orders either way.
+func keysetAfter(sorts []Sort, values []any, id any, flip bool) (string, []any) {
+	keys := append(slices.Clone(sorts), Sort{Column: "id", Desc: sorts[0].Desc})
+	values = append(slices.Clone(values), id)
+
+	var (
+		alternatives []string
+		args         []any
+		equal        []string
+		equalArgs    []any
+	)
+
+	for i, key := range keys {
+		column, value := clause.Column{Name: key.Column}, values[i]
+
+		var after string
+		var afterArgs []any
+
+		switch desc := key.Desc != flip; {
+		case value == nil && desc:
+			after, afterArgs = "? IS NOT NULL", []any{column}
+		case value == nil:
+			// nothing comes after NULL going up
+		case desc:
+			after, afterArgs = "? < ?", []any{column, value}
+		case i == len(keys)-1:
+			// ids are never NULL
+			after, afterArgs = "? > ?", []any{column, value}
+		default:
+			after, afterArgs = "(? > ? OR ? IS NULL)", []any{column, value, column}
+		}
+
+		if after != "" {
+			alternatives = append(alternatives, "("+strings.Join(append(slices.Clone(equal), after), " AND ")+")")
+			args = append(append(args, equalArgs...), afterArgs...)
+		}
+
+		if value == nil {
+			equal, equalArgs = append(equal, "? IS NULL"), append(equalArgs, column)
+		} else {
+			equal, equalArgs = append(equal, "? = ?"), append(equalArgs, column, value)
+		}
+	}
+
+	if len(alternatives) == 0 {
+		return "FALSE", nil
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+// keyValue turns the value of a sort column into what is stored in the
+// cursor, nil for NULL.
+func keyValue(value any) (any, error) {
+	if valuer, ok := value.(driver.Valuer); ok {
+		return valuer.Value()
+	}
+
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		return v.Elem().Interface(), nil
+	}
+
+	return value, nil
+}
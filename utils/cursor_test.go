@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"errors"
+	"gin-rest-api/config"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "secret"}
+
+	want := cursor{
+		Sorts:  []Sort{{Column: "published_at", Desc: true}, {Column: "title"}},
+		Values: []any{nil, "hello"},
+		ID:     uint64(1 << 60),
+	}
+
+	token, err := encodeCursor(cfg, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := decodeCursor(cfg, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != int64(1<<60) || !reflect.DeepEqual(got.Values, want.Values) || !reflect.DeepEqual(got.Sorts, want.Sorts) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	t.Run("tampered", func(t *testing.T) {
+		encoded, signature, _ := strings.Cut(token, ".")
+		if _, err := decodeCursor(cfg, encoded+"x."+signature); !errors.Is(err, ErrInvalidCursor) {
+			t.Fatalf("got %v, want ErrInvalidCursor", err)
+		}
+		if _, err := decodeCursor(&config.Config{JWTSecret: "other"}, token); !errors.Is(err, ErrInvalidCursor) {
+			t.Fatalf("got %v, want ErrInvalidCursor", err)
+		}
+	})
+}
+
+func TestKeysetAfter(t *testing.T) {
+	sorts := []Sort{{Column: "published_at", Desc: true}, {Column: "title"}}
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		values []any
+		before bool
+		want   string
+	}{
+		{
+			name:   "after a value",
+			values: []any{day, "b"},
+			want: `(("published_at" < '2024-01-02 00:00:00')` +
+				` OR ("published_at" = '2024-01-02 00:00:00' AND ("title" > 'b' OR "title" IS NULL))` +
+				` OR ("published_at" = '2024-01-02 00:00:00' AND "title" = 'b' AND "id" < 7))`,
+		},
+		{
+			name:   "after NULL",
+			values: []any{nil, nil},
+			want: `(("published_at" IS NOT NULL)` +
+				` OR ("published_at" IS NULL AND "title" IS NULL AND "id" < 7))`,
+		},
+		{
+			name:   "before NULL",
+			values: []any{nil, "b"},
+			before: true,
+			want: `(("published_at" IS NULL AND "title" < 'b')` +
+				` OR ("published_at" IS NULL AND "title" = 'b' AND "id" > 7))`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			condition, args := keysetAfter(sorts, test.values, 7, test.before)
+
+			sql := dryRun(t).ToSQL(func(tx *gorm.DB) *gorm.DB {
+				var rows []map[string]any
+				return tx.Table("posts").Where(condition, args...).Find(&rows)
+			})
+			if !strings.Contains(sql, test.want) {
+				t.Fatalf("got %s, want %s", sql, test.want)
+			}
+		})
+	}
+}
+
+func TestKeyValue(t *testing.T) {
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value, want any
+	}{
+		{(*time.Time)(nil), nil},
+		{&day, day},
+		{gorm.DeletedAt{}, nil},
+		{gorm.DeletedAt{Time: day, Valid: true}, day},
+		{uint(0), uint(0)},
+	}
+
+	for _, test := range tests {
+		got, err := keyValue(test.value)
+		if err != nil || got != test.want {
+			t.Fatalf("keyValue(%#v) = %v, %v, want %v", test.value, got, err, test.want)
+		}
+	}
+}
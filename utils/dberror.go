@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgInvalidDatetime     = "22007"
+	pgInvalidText         = "22P02"
+	pgNotNullViolation    = "23502"
+	pgForeignKeyViolation = "23503"
+	pgUniqueViolation     = "23505"
+	pgCheckViolation      = "23514"
+)
+
+var (
+	pgKeyDetail        = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+	pgReferencedDetail = regexp.MustCompile(`is still referenced from table "([^"]+)"`)
+)
+
+type DBError struct {
+	Status  int
+	Field   string
+	Message string
+}
+
+// TranslateDBError maps a constraint violation returned by Postgres to an
+// HTTP status and the offending field. It reports false for any other error.
+func TranslateDBError(err error) (DBError, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return DBError{}, false
+	}
+
+	column := pgErr.ColumnName
+	if column == "" {
+		column = keyColumn(pgErr.Detail)
+	}
+	if column == "" {
+		column = pgErr.ConstraintName
+	}
+
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		return DBError{
+			Status:  http.StatusConflict,
+			Field:   fieldName(column),
+			Message: fmt.Sprintf("the %s is already exist!", humanize(column)),
+		}, true
+	case pgForeignKeyViolation:
+		// deleting a row that other rows still point to
+		if match := pgReferencedDetail.FindStringSubmatch(pgErr.Detail); match != nil {
+			return DBError{
+				Status:  http.StatusConflict,
+				Field:   fieldName(column),
+				Message: fmt.Sprintf("the record is still referenced from %s", humanize(match[1])),
+			}, true
+		}
+
+		return DBError{
+			Status:  http.StatusUnprocessableEntity,
+			Field:   fieldName(column),
+			Message: fmt.Sprintf("the %s does not exist!", humanize(strings.TrimSuffix(column, "_id"))),
+		}, true
+	case pgNotNullViolation:
+		return DBError{
+			Status:  http.StatusUnprocessableEntity,
+			Field:   fieldName(column),
+			Message: fmt.Sprintf("the %s is required", humanize(column)),
+		}, true
+	case pgInvalidText, pgInvalidDatetime:
+		field := "value"
+		if column != "" {
+			field = fieldName(column)
+		}
+		return DBError{
+			Status:  http.StatusBadRequest,
+			Field:   field,
+			Message: pgErr.Message,
+		}, true
+	case pgCheckViolation:
+		return DBError{
+			Status:  http.StatusUnprocessableEntity,
+			Field:   fieldName(column),
+			Message: fmt.Sprintf("the %s is invalid", humanize(column)),
+		}, true
+	}
+
+	return DBError{}, false
+}
+
+// keyColumn extracts the first column from details such as
+// `Key (email)=(user@mail.com) already exists.`
+func keyColumn(detail string) string {
+	match := pgKeyDetail.FindStringSubmatch(detail)
+	if match == nil {
+		return ""
+	}
+
+	column, _, _ := strings.Cut(match[1], ",")
+	return strings.TrimSpace(column)
+}
+
+// fieldName turns a column name into the JSON field name used in request
+// validation errors, e.g. category_id becomes categoryId.
+func fieldName(column string) string {
+	parts := strings.Split(column, "_")
+	for i, part := range parts {
+		if i > 0 && part != "" {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+func humanize(column string) string {
+	return strings.ReplaceAll(column, "_", " ")
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestTranslateDBError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *pgconn.PgError
+		want DBError
+	}{
+		{
+			name: "unique violation",
+			err:  &pgconn.PgError{Code: pgUniqueViolation, Detail: "Key (email)=(user@mail.com) already exists."},
+			want: DBError{http.StatusConflict, "email", "the email is already exist!"},
+		},
+		{
+			name: "missing reference",
+			err:  &pgconn.PgError{Code: pgForeignKeyViolation, Detail: `Key (category_id)=(9) is not present in table "categories".`},
+			want: DBError{http.StatusUnprocessableEntity, "categoryId", "the category does not exist!"},
+		},
+		{
+			name: "still referenced",
+			err:  &pgconn.PgError{Code: pgForeignKeyViolation, Detail: `Key (id)=(3) is still referenced from table "post_revisions".`},
+			want: DBError{http.StatusConflict, "id", "the record is still referenced from post revisions"},
+		},
+		{
+			name: "not null violation",
+			err:  &pgconn.PgError{Code: pgNotNullViolation, ColumnName: "user_id"},
+			want: DBError{http.StatusUnprocessableEntity, "userId", "the user id is required"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := TranslateDBError(fmt.Errorf("wrapped: %w", tt.err))
+			if !ok {
+				t.Fatal("the error was not translated")
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := TranslateDBError(errors.New("connection refused")); ok {
+		t.Fatal("a non Postgres error was translated")
+	}
+}
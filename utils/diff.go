@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+const (
+	// MaxDiffTokens bounds the words or lines of the texts compared.
+	MaxDiffTokens = 50000
+	// MaxDiffEdits bounds the edit distance searched for. The memory a diff
+	// takes grows with its square.
+	MaxDiffEdits = 2000
+)
+
+var ErrDiffTooLarge = errors.New("the texts are too large or too different to be compared")
+
+// DiffChunk is a run of text that is in both versions, or only in the old
+// (delete) or the new (insert) one.
+type DiffChunk struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+type edit struct {
+	op   string
+	text string
+	a, b int // positions in the old and the new version before the edit
+}
+
+var wordToken = regexp.MustCompile(`\w+|\s+|[^\w\s]`)
+
+// WordDiff compares two texts word by word, keeping whitespace and
+// punctuation as tokens of their own.
+func WordDiff(from, to string) ([]DiffChunk, error) {
+	edits, err := diff(wordToken.FindAllString(from, -1), wordToken.FindAllString(to, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := []DiffChunk{}
+
+	for _, e := range edits {
+		if n := len(chunks); n > 0 && chunks[n-1].Op == e.op {
+			chunks[n-1].Text += e.text
+			continue
+		}
+		chunks = append(chunks, DiffChunk{e.op, e.text})
+	}
+
+	return chunks, nil
+}
+
+// UnifiedDiff compares two texts line by line in the unified format, with
+// context lines of context around every change.
+func UnifiedDiff(fromName, toName, from, to string, context int) (string, error) {
+	edits, err := diff(strings.Split(from, "\n"), strings.Split(to, "\n"))
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+
+	for i := 0; i < len(edits); i++ {
+		if edits[i].op == DiffEqual {
+			continue
+		}
+
+		// a hunk runs until there are more than two contexts of equal lines
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < len(edits) && j <= end+2*context; j++ {
+			if edits[j].op != DiffEqual {
+				end = j
+			}
+		}
+		end = min(end+context, len(edits)-1)
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+
+		var fromCount, toCount int
+		for _, e := range edits[start : end+1] {
+			if e.op != DiffInsert {
+				fromCount++
+			}
+			if e.op != DiffDelete {
+				toCount++
+			}
+		}
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(edits[start].a, fromCount), hunkRange(edits[start].b, toCount))
+
+		for _, e := range edits[start : end+1] {
+			switch e.op {
+			case DiffEqual:
+				out.WriteString(" ")
+			case DiffDelete:
+				out.WriteString("-")
+			case DiffInsert:
+				out.WriteString("+")
+			}
+			out.WriteString(e.text + "\n")
+		}
+
+		i = end
+	}
+
+	return out.String(), nil
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// diff returns the shortest edit script from a to b, following Myers'
+// O(ND) algorithm, or ErrDiffTooLarge past MaxDiffTokens or MaxDiffEdits.
+func diff(a, b []string) ([]edit, error) {
+	n, m := len(a), len(b)
+	if n+m > MaxDiffTokens {
+		return nil, ErrDiffTooLarge
+	}
+
+	limit := min(n+m, MaxDiffEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+
+	// trace[d] keeps the diagonals -d-1..d+1 as they were before step d
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, slices.Clone(v[offset-d-1:offset+d+2]))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(a, b, trace), nil
+			}
+		}
+	}
+
+	return nil, ErrDiffTooLarge
+}
+
+func backtrack(a, b []string, trace [][]int) []edit {
+	var edits []edit
+
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY && x > 0 && y > 0 {
+			x--
+			y--
+			edits = append(edits, edit{DiffEqual, a[x], x, y})
+		}
+
+		if d == 0 {
+			break
+		}
+
+		if x == prevX {
+			y--
+			edits = append(edits, edit{DiffInsert, b[y], x, y})
+		} else {
+			x--
+			edits = append(edits, edit{DiffDelete, a[x], x, y})
+		}
+	}
+
+	slices.Reverse(edits)
+	return edits
+}
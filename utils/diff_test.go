@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestWordDiff(t *testing.T) {
+	chunks, err := WordDiff("the quick brown fox", "the slow brown fox!")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []DiffChunk{
+		{DiffEqual, "the "},
+		{DiffDelete, "quick"},
+		{DiffInsert, "slow"},
+		{DiffEqual, " brown fox"},
+		{DiffInsert, "!"},
+	}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Fatalf("got %v, want %v", chunks, want)
+	}
+
+	chunks, err = WordDiff("", "")
+	if err != nil || len(chunks) != 0 {
+		t.Fatalf("got %v, %v for two empty texts", chunks, err)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj"
+	to := "a\nb\nc\nD\ne\nf\ng\nh\ni\nj\nk"
+
+	got, err := UnifiedDiff("old", "new", from, to, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "--- old\n+++ new\n" +
+		"@@ -3,3 +3,3 @@\n c\n-d\n+D\n e\n" +
+		"@@ -10 +10,2 @@\n j\n+k\n"
+	if got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+
+	got, err = UnifiedDiff("old", "new", from, from, 3)
+	if err != nil || got != "" {
+		t.Fatalf("got %q, %v for identical texts", got, err)
+	}
+}
+
+func TestDiffTooLarge(t *testing.T) {
+	words := func(prefix string, n int) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&b, "%s%d ", prefix, i)
+		}
+		return b.String()
+	}
+
+	t.Run("too different", func(t *testing.T) {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+
+		_, err := WordDiff(words("a", 3000), words("b", 3000))
+
+		runtime.ReadMemStats(&after)
+
+		if !errors.Is(err, ErrDiffTooLarge) {
+			t.Fatalf("got %v, want ErrDiffTooLarge", err)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+			t.Fatalf("allocated %d bytes", allocated)
+		}
+	})
+
+	t.Run("too long", func(t *testing.T) {
+		text := words("a", MaxDiffTokens)
+		if _, err := WordDiff(text, text); !errors.Is(err, ErrDiffTooLarge) {
+			t.Fatalf("got %v, want ErrDiffTooLarge", err)
+		}
+	})
+
+	t.Run("long but close", func(t *testing.T) {
+		text := words("a", 5000)
+		chunks, err := WordDiff(text, text+"end")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(chunks) != 2 || chunks[1] != (DiffChunk{DiffInsert, "end"}) {
+			t.Fatalf("got %v", chunks[len(chunks)-1])
+		}
+	})
+}
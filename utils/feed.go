@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"context"
+	"gin-rest-api/config"
+	"gin-rest-api/models"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Feed caches in Redis, for every user, a sorted set of the newest published
+// posts of the authors and categories the user follows, scored by publication
+// time. A feed is built from the database on a miss, and newly published
+// posts are pushed into the feeds that are already cached.
+type Feed struct {
+	cfg *config.Config
+	db  *gorm.DB
+	rds *redis.Client
+}
+
+// pushScript adds a post to a feed only if the feed is cached, atomically,
+// so that a feed expiring meanwhile is not recreated with a single post and
+// no TTL.
+var pushScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+	redis.call("ZREMRANGEBYRANK", KEYS[1], 0, ARGV[3])
+end
+return 0`)
+
+func NewFeed(cfg *config.Config, db *gorm.DB, rds *redis.Client) *Feed {
+	return &Feed{cfg, db, rds}
+}
+
+func feedKey(userId uint) string {
+	return "feed_" + strconv.Itoa(int(userId))
+}
+
+// PostIDs returns the IDs of the posts in the feed of the user, newest first.
+func (f *Feed) PostIDs(ctx context.Context, userId uint) ([]uint, error) {
+	members, err := f.rds.ZRevRange(ctx, feedKey(userId), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(members) == 0 {
+		return f.build(ctx, userId)
+	}
+
+	ids := make([]uint, len(members))
+	for i, member := range members {
+		id, err := strconv.ParseUint(member, 10, 0)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = uint(id)
+	}
+
+	return ids, nil
+}
+
+func (f *Feed) build(ctx context.Context, userId uint) ([]uint, error) {
+	var posts []models.Post
+
+	err := f.db.Select("id", "published_at").Scopes(followedBy(userId)).
+		Where("status = ?", models.PostPublished).
+		Order("published_at DESC, id DESC").Limit(f.cfg.FeedSize).
+		Find(&posts).Error
+	if err != nil || len(posts) == 0 {
+		return nil, err
+	}
+
+	ids := make([]uint, len(posts))
+	members := make([]redis.Z, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+		members[i] = feedMember(post)
+	}
+
+	key := feedKey(userId)
+
+	_, err = f.rds.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.ZAdd(ctx, key, members...)
+		pipe.Expire(ctx, key, time.Duration(f.cfg.FeedTTL)*time.Second)
+		return nil
+	})
+
+	return ids, err
+}
+
+// Push adds newly published posts to the cached feeds of the followers of
+// their authors and categories, including the followers of the parent
+// categories. Feeds that are not cached pick the posts up when they are built.
+func (f *Feed) Push(ctx context.Context, posts ...models.Post) error {
+	for _, post := range posts {
+		if post.PublishedAt == nil {
+			continue
+		}
+
+		var followers []uint
+
+		err := f.db.Model(&models.Follow{}).Distinct("follower_id").
+			Where(`(target_type = ? AND target_id = ?) OR (target_type = ? AND target_id IN (
+				WITH RECURSIVE ancestors AS (
+					SELECT id, parent_id FROM categories WHERE id = ?
+					UNION ALL
+					SELECT categories.id, categories.parent_id FROM categories JOIN ancestors ON categories.id = ancestors.parent_id
+				)
+				SELECT id FROM ancestors
+			))`, models.FollowUsers, post.UserID, models.FollowCategories, post.CategoryID).
+			Pluck("follower_id", &followers).Error
+		if err != nil {
+			return err
+		}
+
+		if len(followers) == 0 {
+			continue
+		}
+
+		member := feedMember(post)
+
+		_, err = f.rds.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, follower := range followers {
+				pushScript.Eval(ctx, pipe, []string{feedKey(follower)}, member.Score, member.Member, -f.cfg.FeedSize-1)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Invalidate drops the cached feed of the user, to be rebuilt on next read.
+func (f *Feed) Invalidate(ctx context.Context, userId uint) error {
+	return f.rds.Del(ctx, feedKey(userId)).Err()
+}
+
+func feedMember(post models.Post) redis.Z {
+	return redis.Z{Score: float64(post.PublishedAt.UnixMilli()), Member: post.ID}
+}
+
+// followedBy limits a query of posts to those of the authors and the
+// categories, with their subcategories, that the user follows.
+func followedBy(userId uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`(user_id IN (
+			SELECT target_id FROM follows WHERE follower_id = ? AND target_type = ?
+		) OR category_id IN (
+			WITH RECURSIVE tree AS (
+				SELECT target_id AS id FROM follows WHERE follower_id = ? AND target_type = ?
+				UNION
+				SELECT categories.id FROM categories JOIN tree ON categories.parent_id = tree.id
+				WHERE categories.deleted_at IS NULL
+			)
+			SELECT id FROM tree
+		))`, userId, models.FollowUsers, userId, models.FollowCategories)
+	}
+}
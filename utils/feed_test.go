@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"context"
+	"gin-rest-api/config"
+	"gin-rest-api/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestFeedPush(t *testing.T) {
+	rds, server := testRedis(t)
+
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	feed := NewFeed(&config.Config{FeedSize: 2, FeedTTL: 3600}, db, rds)
+
+	// follower 1 has a full cached feed, the feed of follower 2 expired
+	rds.ZAdd(ctx, feedKey(1), feedMember(testPost(1, 1000)), feedMember(testPost(2, 2000)))
+	rds.Expire(ctx, feedKey(1), time.Hour)
+
+	mock.ExpectQuery(`SELECT DISTINCT "follower_id" FROM "follows"`).
+		WillReturnRows(sqlmock.NewRows([]string{"follower_id"}).AddRow(1).AddRow(2))
+
+	if err := feed.Push(ctx, testPost(3, 3000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	members, _ := rds.ZRevRange(ctx, feedKey(1), 0, -1).Result()
+	if len(members) != 2 || members[0] != "3" || members[1] != "2" {
+		t.Fatalf("got feed %v, want the 2 newest posts", members)
+	}
+	if server.TTL(feedKey(1)) <= 0 {
+		t.Fatal("the cached feed lost its TTL")
+	}
+
+	if server.Exists(feedKey(2)) {
+		t.Fatal("an expired feed was recreated")
+	}
+}
+
+func testPost(id uint, publishedAt int64) models.Post {
+	at := time.UnixMilli(publishedAt)
+
+	post := models.Post{PublishedAt: &at}
+	post.ID = id
+	return post
+}
@@ -0,0 +1,302 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const defaultIncludeDepth = 2
+
+// Field is a selectable attribute of a resource.
+type Field struct {
+	Column string    // database column
+	Key    string    // key in the JSON payload
+	Type   FieldType // type of the values it is filtered on
+}
+
+// Relation is a selectable association of a resource. LocalKey is the column
+// the parent needs to load it and ForeignKey the one the related rows need.
+// Only Fields are ever loaded for related rows, and only the rows Scope lets
+// the current user see when it is set.
+type Relation struct {
+	Name       string // GORM association name
+	Key        string // key in the JSON payload
+	LocalKey   string
+	ForeignKey string
+	Fields     map[string]Field
+	Relations  map[string]Relation
+	Scope      func(c *gin.Context) func(*gorm.DB) *gorm.DB
+}
+
+// Resource is the whitelist of fields a client may request, filter and
+// sort on, and of relations it may include. Filters and Sorts name entries
+// of Fields. SortAliases are named sorts such as "newest": "-created_at" and
+// DefaultSort applies when the request has no sort.
+type Resource struct {
+	Fields      map[string]Field
+	Relations   map[string]Relation
+	Filters     []string
+	Sorts       []string
+	SortAliases map[string]string
+	DefaultSort string
+	MaxDepth    int
+}
+
+// Without returns a copy of the resource without the named fields, which
+// can then be neither selected, filtered nor sorted on.
+func (r Resource) Without(names ...string) Resource {
+	fields := make(map[string]Field, len(r.Fields))
+	for name, field := range r.Fields {
+		if !slices.Contains(names, name) {
+			fields[name] = field
+		}
+	}
+
+	r.Fields = fields
+	r.Filters = slices.DeleteFunc(slices.Clone(r.Filters), func(name string) bool { return slices.Contains(names, name) })
+	r.Sorts = slices.DeleteFunc(slices.Clone(r.Sorts), func(name string) bool { return slices.Contains(names, name) })
+	return r
+}
+
+type selectedRelation struct {
+	Relation
+	fields    []Field
+	relations []*selectedRelation
+}
+
+// FieldSet is the parsed value of the fields and include query parameters.
+// A nil fields list selects every attribute.
+type FieldSet struct {
+	c         *gin.Context
+	resource  Resource
+	fields    []Field
+	relations []*selectedRelation
+}
+
+// ParseFields parses `?fields=id,title,user.name` and
+// `?include=comments.user,category` against the resource whitelist.
+// include is used when the request has neither parameter.
+func ParseFields(c *gin.Context, resource Resource, include string) (*FieldSet, error) {
+	set := &FieldSet{c: c, resource: resource}
+
+	fieldsParam, hasFields := c.GetQuery("fields")
+	includeParam, hasInclude := c.GetQuery("include")
+	if !hasFields && !hasInclude {
+		includeParam = include
+	}
+
+	maxDepth := resource.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = defaultIncludeDepth
+	}
+
+	for _, path := range strings.Split(includeParam, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		names := strings.Split(path, ".")
+		if len(names) > maxDepth {
+			return nil, fmt.Errorf("the include %s is deeper than %d", path, maxDepth)
+		}
+
+		relations := &set.relations
+		whitelist := resource.Relations
+		for _, name := range names {
+			relation, ok := whitelist[name]
+			if !ok {
+				return nil, fmt.Errorf("the include %s is not allowed", path)
+			}
+
+			selected := selectRelation(relations, relation)
+			relations = &selected.relations
+			whitelist = relation.Relations
+		}
+	}
+
+	if !hasFields {
+		return set, nil
+	}
+
+	set.fields = []Field{}
+
+	for _, name := range strings.Split(fieldsParam, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		relName, fieldName, nested := strings.Cut(name, ".")
+		if !nested {
+			field, ok := resource.Fields[name]
+			if !ok {
+				return nil, fmt.Errorf("the field %s is not allowed", name)
+			}
+			if !slices.Contains(set.fields, field) {
+				set.fields = append(set.fields, field)
+			}
+			continue
+		}
+
+		relation, ok := resource.Relations[relName]
+		if !ok {
+			return nil, fmt.Errorf("the field %s is not allowed", name)
+		}
+		field, ok := relation.Fields[fieldName]
+		if !ok {
+			return nil, fmt.Errorf("the field %s is not allowed", name)
+		}
+
+		selected := selectRelation(&set.relations, relation)
+		if selected.fields == nil {
+			selected.fields = []Field{}
+		}
+		if !slices.Contains(selected.fields, field) {
+			selected.fields = append(selected.fields, field)
+		}
+	}
+
+	return set, nil
+}
+
+func selectRelation(relations *[]*selectedRelation, relation Relation) *selectedRelation {
+	for _, selected := range *relations {
+		if selected.Name == relation.Name {
+			return selected
+		}
+	}
+
+	selected := &selectedRelation{Relation: relation}
+	*relations = append(*relations, selected)
+	return selected
+}
+
+// Scope restricts the query to the selected columns and preloads the
+// selected relations with their selected columns.
+func (f *FieldSet) Scope(db *gorm.DB) *gorm.DB {
+	for _, relation := range f.relations {
+		db = f.preload(db, "", relation)
+	}
+
+	if f.fields == nil {
+		return db
+	}
+
+	columns := []string{"id"}
+	for _, field := range f.fields {
+		columns = appendColumn(columns, field.Column)
+	}
+	for _, relation := range f.relations {
+		columns = appendColumn(columns, relation.LocalKey)
+	}
+
+	return db.Select(columns)
+}
+
+func (f *FieldSet) preload(db *gorm.DB, prefix string, relation *selectedRelation) *gorm.DB {
+	columns := []string{"id", relation.ForeignKey}
+	for _, field := range relation.selectedFields() {
+		columns = appendColumn(columns, field.Column)
+	}
+	for _, child := range relation.relations {
+		columns = appendColumn(columns, child.LocalKey)
+	}
+
+	path := prefix + relation.Name
+	db = db.Preload(path, func(tx *gorm.DB) *gorm.DB {
+		if relation.Scope != nil {
+			tx = relation.Scope(f.c)(tx)
+		}
+		return tx.Select(columns)
+	})
+
+	for _, child := range relation.relations {
+		db = f.preload(db, path+".", child)
+	}
+
+	return db
+}
+
+func (r *selectedRelation) selectedFields() []Field {
+	if r.fields != nil {
+		return r.fields
+	}
+
+	fields := make([]Field, 0, len(r.Fields))
+	for _, field := range r.Fields {
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// Pick drops every key of data, a record, a list of records or a page of
+// records, that was not selected or included.
+func (f *FieldSet) Pick(data any) (any, error) {
+	tree, err := toTree(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := data.(paginated); ok {
+		page := tree.(map[string]any)
+		page["data"] = pickValue(page["data"], f.fields, f.resource.Relations, f.relations)
+		return page, nil
+	}
+
+	return pickValue(tree, f.fields, f.resource.Relations, f.relations), nil
+}
+
+// pickValue keeps the fields, or every attribute when fields is nil, and the
+// selected relations of a record or a list of records.
+func pickValue(value any, fields []Field, whitelist map[string]Relation, relations []*selectedRelation) any {
+	switch v := value.(type) {
+	case []any:
+		for i, item := range v {
+			v[i] = pickValue(item, fields, whitelist, relations)
+		}
+		return v
+	case map[string]any:
+		picked := make(map[string]any, len(relations))
+		for _, relation := range relations {
+			picked[relation.Key] = pickValue(v[relation.Key], relation.selectedFields(), relation.Relations, relation.relations)
+		}
+
+		out := v
+		if fields == nil {
+			for _, relation := range whitelist {
+				delete(out, relation.Key)
+			}
+		} else {
+			out = pickKeys(v, fields)
+		}
+
+		for key, value := range picked {
+			out[key] = value
+		}
+		return out
+	}
+
+	return value
+}
+
+func pickKeys(record map[string]any, fields []Field) map[string]any {
+	out := make(map[string]any, len(fields))
+	for _, field := range fields {
+		if value, ok := record[field.Key]; ok {
+			out[field.Key] = value
+		}
+	}
+	return out
+}
+
+func appendColumn(columns []string, column string) []string {
+	if column == "" || slices.Contains(columns, column) {
+		return columns
+	}
+	return append(columns, column)
+}
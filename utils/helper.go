@@ -1,7 +1,7 @@
 package utils
 
 import (
-	"fmt"
+	"gin-rest-api/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -16,37 +16,31 @@ func GetUserID(c *gin.Context) uint {
 	return userId.(uint)
 }
 
-func IsExist(db *gorm.DB, table, field string, value any) bool {
-	var count int64
-
-	result := db.Table(table).Where(field+" = ?", value).Count(&count)
-	if result.Error != nil {
-		return false
+// GetUserRole returns the role of the current user, read once per request.
+func GetUserRole(c *gin.Context, db *gorm.DB) string {
+	if role, exists := c.Get("userRole"); exists {
+		return role.(string)
 	}
 
-	return count > 0
+	var role string
+	db.Model(&models.User{}).Where("id = ?", GetUserID(c)).Select("role").Scan(&role)
+
+	c.Set("userRole", role)
+	return role
+}
+
+// IsModerator reports whether the current user may moderate content.
+func IsModerator(c *gin.Context, db *gorm.DB) bool {
+	role := GetUserRole(c, db)
+	return role == models.RoleModerator || role == models.RoleAdmin
 }
 
-func FormatErrors(errs validator.ValidationErrors) map[string]string {
+func FormatErrors(c *gin.Context, errs validator.ValidationErrors) map[string]string {
+	trans := GetTranslator(c)
 	message := make(map[string]string)
 
 	for _, err := range errs {
-		switch err.Tag() {
-		case "required":
-			message[err.Field()] = fmt.Sprintf("%s is required", err.Field())
-		case "email":
-			message[err.Field()] = fmt.Sprintf("%s must be a valid email address", err.Field())
-		case "min":
-			message[err.Field()] = fmt.Sprintf("%s must have at least %s characters", err.Field(), err.Param())
-		case "max":
-			message[err.Field()] = fmt.Sprintf("%s must have at most %s characters", err.Field(), err.Param())
-		case "gt":
-			message[err.Field()] = fmt.Sprintf("%s must be greater than %s", err.Field(), err.Param())
-		case "gte":
-			message[err.Field()] = fmt.Sprintf("%s must be greater than or equal to %s", err.Field(), err.Param())
-		default:
-			message[err.Field()] = fmt.Sprintf("validation on field %s", err.Field())
-		}
+		message[err.Field()] = err.Translate(trans)
 	}
 
 	return message
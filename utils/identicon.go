@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+const identiconGrid = 5
+
+// Identicon writes a PNG of a horizontally symmetric 5x5 pattern, size
+// pixels wide, whose cells and color are derived from seed.
+func Identicon(w io.Writer, seed string, size int) error {
+	sum := sha256.Sum256([]byte(seed))
+
+	background := color.RGBA{240, 240, 240, 255}
+	foreground := color.RGBA{sum[0] / 2, sum[1] / 2, sum[2] / 2, 255}
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{background, foreground})
+
+	cell := size / (identiconGrid + 1)
+	margin := (size - cell*identiconGrid) / 2
+
+	for row := 0; row < identiconGrid; row++ {
+		for col := 0; col < (identiconGrid+1)/2; col++ {
+			if sum[3+row*identiconGrid+col]%2 == 0 {
+				continue
+			}
+
+			for _, mirrored := range []int{col, identiconGrid - 1 - col} {
+				x0, y0 := margin+mirrored*cell, margin+row*cell
+				for y := y0; y < y0+cell; y++ {
+					for x := x0; x < x0+cell; x++ {
+						img.SetColorIndex(x, y, 1)
+					}
+				}
+			}
+		}
+	}
+
+	return png.Encode(w, img)
+}
@@ -1,45 +1,138 @@
 package utils
 
-import "gorm.io/gorm"
+import (
+	"errors"
+	"strconv"
 
-type PaginateResult struct {
-	Data        any   `json:"data"`
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPerPage = 10
+	MaxPerPage     = 100
+)
+
+// Page is the result every list endpoint returns. Exactly one of PageOffset
+// and PageCursor is set, depending on the pagination mode.
+type Page[T any] struct {
+	Data    []T `json:"data"`
+	PerPage int `json:"per_page"`
+	*PageOffset
+	*PageCursor
+}
+
+type PageOffset struct {
 	CurrentPage int   `json:"current_page"`
 	From        int   `json:"from"`
 	To          int   `json:"to"`
 	LastPage    int   `json:"last_page"`
-	PerPage     int   `json:"per_page"`
 	Total       int64 `json:"total"`
 }
 
-func Paginate(db *gorm.DB, page, limit int, rawFunc func(*gorm.DB) *gorm.DB, output any) (PaginateResult, error) {
-	offset := (page - 1) * limit
+type PageCursor struct {
+	Next string `json:"next_cursor"`
+	Prev string `json:"prev_cursor"`
+}
+
+// paginated is implemented by every Page[T].
+type paginated interface {
+	paginated()
+}
+
+func (Page[T]) paginated() {}
+
+// PageParams are the validated pagination parameters of a request.
+type PageParams struct {
+	Page      int
+	PerPage   int
+	UseCursor bool
+	Cursor    string
+	List      *ListQuery
+}
+
+// ParsePageParams reads page/perPage, or cursor/limit for cursor mode, and
+// the filter and sort parameters of the resource. perPage and limit are
+// capped at MaxPerPage.
+func ParsePageParams(c *gin.Context, resource Resource) (PageParams, error) {
+	list, err := ParseListQuery(c, resource)
+	if err != nil {
+		return PageParams{}, err
+	}
+
+	params := PageParams{Page: 1, PerPage: DefaultPerPage, List: list}
+
+	token, hasCursor := c.GetQuery("cursor")
+	_, hasLimit := c.GetQuery("limit")
+
+	if hasCursor || hasLimit {
+		params.UseCursor = true
+		params.Cursor = token
+		params.PerPage, err = positiveQuery(c, "limit", DefaultPerPage)
+		if err != nil {
+			return PageParams{}, err
+		}
+	} else {
+		if params.Page, err = positiveQuery(c, "page", 1); err != nil {
+			return PageParams{}, err
+		}
+		if params.PerPage, err = positiveQuery(c, "perPage", DefaultPerPage); err != nil {
+			return PageParams{}, err
+		}
+	}
+
+	params.PerPage = min(params.PerPage, MaxPerPage)
+	return params, nil
+}
+
+func positiveQuery(c *gin.Context, key string, defaultValue int) (int, error) {
+	value, ok := c.GetQuery(key)
+	if !ok || value == "" {
+		return defaultValue, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		return 0, errors.New(key + " must be a positive number")
+	}
+
+	return number, nil
+}
 
-	query := db
-	if rawFunc != nil {
-		query = rawFunc(query)
+// Paginate returns the requested page of the filtered and sorted query.
+func Paginate[T any](db *gorm.DB, params PageParams, scopes ...func(*gorm.DB) *gorm.DB) (Page[T], error) {
+	query := params.List.Scope(db)
+	for _, scope := range scopes {
+		query = scope(query)
 	}
+	query = params.List.Order(query).Session(&gorm.Session{})
 
 	var total int64
-	query.Model(output).Count(&total)
+	if err := query.Model(new(T)).Count(&total).Error; err != nil {
+		return Page[T]{}, err
+	}
 
-	err := query.Find(output).Offset(offset).Limit(limit).Error
-	if err != nil {
-		return PaginateResult{}, nil
+	offset := (params.Page - 1) * params.PerPage
+
+	rows := []T{}
+	if err := query.Offset(offset).Limit(params.PerPage).Find(&rows).Error; err != nil {
+		return Page[T]{}, err
 	}
 
-	to := offset + limit
-	if to > int(total) {
-		to = int(total)
+	from, to := 0, 0
+	if len(rows) > 0 {
+		from, to = offset+1, offset+len(rows)
 	}
 
-	return PaginateResult{
-		Data:        output,
-		CurrentPage: page,
-		From:        offset + 1,
-		To:          to,
-		LastPage:    (int(total) + limit - 1) / limit,
-		PerPage:     limit,
-		Total:       total,
+	return Page[T]{
+		Data:    rows,
+		PerPage: params.PerPage,
+		PageOffset: &PageOffset{
+			CurrentPage: params.Page,
+			From:        from,
+			To:          to,
+			LastPage:    int((total + int64(params.PerPage) - 1) / int64(params.PerPage)),
+			Total:       total,
+		},
 	}, nil
 }
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var filterParam = regexp.MustCompile(`^filter\[(\w+)\](?:\[(\w+)\])?$`)
+
+// FieldType is the type of the values a field is filtered on. The zero
+// value is text.
+type FieldType int
+
+const (
+	FieldText FieldType = iota
+	FieldInt
+	FieldTime
+	FieldBool
+)
+
+// filterOps are the filter operators every type of field supports.
+var filterOps = map[FieldType][]string{
+	FieldText: {"eq", "ne", "like", "in"},
+	FieldInt:  {"eq", "ne", "lt", "gt", "in", "between"},
+	FieldTime: {"eq", "ne", "lt", "gt", "between"},
+	FieldBool: {"eq", "ne"},
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+type Sort struct {
+	Column string
+	Desc   bool
+}
+
+type filter struct {
+	column string
+	op     string
+	values []any
+}
+
+// ListQuery is the parsed `filter[field][op]=value` and `sort=-field`
+// parameters of a list request. A nil ListQuery neither filters nor sorts.
+type ListQuery struct {
+	filters []filter
+	sorts   []Sort
+}
+
+// ParseListQuery parses the filter and sort parameters against the
+// resource's filterable and sortable fields.
+func ParseListQuery(c *gin.Context, resource Resource) (*ListQuery, error) {
+	var err error
+
+	list := &ListQuery{}
+	params := c.Request.URL.Query()
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		match := filterParam.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+
+		name, op := match[1], match[2]
+		if op == "" {
+			op = "eq"
+		}
+
+		field, ok := resource.Fields[name]
+		if !ok || !slices.Contains(resource.Filters, name) {
+			return nil, fmt.Errorf("the field %s is not filterable", name)
+		}
+
+		if !slices.Contains(filterOps[field.Type], op) {
+			return nil, fmt.Errorf("the filter operator %s is not supported on %s", op, name)
+		}
+
+		value := params.Get(key)
+		raw := []string{value}
+
+		switch op {
+		case "in":
+			raw = strings.Split(value, ",")
+		case "between":
+			raw = strings.Split(value, ",")
+			if len(raw) != 2 {
+				return nil, fmt.Errorf("the filter %s between needs two values", name)
+			}
+		}
+
+		values := make([]any, len(raw))
+		for i, value := range raw {
+			if values[i], err = parseFilterValue(field.Type, value); err != nil {
+				return nil, fmt.Errorf("the filter %s %s", name, err)
+			}
+		}
+
+		list.filters = append(list.filters, filter{column: field.Column, op: op, values: values})
+	}
+
+	sortParam := c.Query("sort")
+	if sortParam == "" {
+		sortParam = resource.DefaultSort
+	}
+
+	for _, name := range strings.Split(sortParam, ",") {
+		name = strings.TrimSpace(name)
+		if alias, ok := resource.SortAliases[name]; ok {
+			name = alias
+		}
+		if name == "" {
+			continue
+		}
+
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+
+		field, ok := resource.Fields[name]
+		if !ok || !slices.Contains(resource.Sorts, name) {
+			return nil, fmt.Errorf("the field %s is not sortable", name)
+		}
+
+		list.sorts = append(list.sorts, Sort{Column: field.Column, Desc: desc})
+	}
+
+	return list, nil
+}
+
+// parseFilterValue converts a filter value to the type of its field, so that
+// malformed values are rejected before they reach the database.
+func parseFilterValue(fieldType FieldType, value string) (any, error) {
+	if fieldType == FieldText {
+		return value, nil
+	}
+
+	value = strings.TrimSpace(value)
+
+	switch fieldType {
+	case FieldInt:
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a whole number")
+		}
+		return number, nil
+	case FieldTime:
+		for _, layout := range []string{time.RFC3339, time.DateOnly} {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("must be a date such as 2006-01-02 or 2006-01-02T15:04:05Z")
+	case FieldBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return b, nil
+	}
+
+	return nil, fmt.Errorf("has an unknown type")
+}
+
+// SortBy returns a ListQuery that only sorts.
+func SortBy(sorts ...Sort) *ListQuery {
+	return &ListQuery{sorts: sorts}
+}
+
+// Scope applies the filters.
+func (l *ListQuery) Scope(db *gorm.DB) *gorm.DB {
+	if l == nil {
+		return db
+	}
+
+	for _, f := range l.filters {
+		column := clause.Column{Name: f.column}
+
+		switch f.op {
+		case "eq":
+			db = db.Where("? = ?", column, f.values[0])
+		case "ne":
+			db = db.Where("? <> ?", column, f.values[0])
+		case "lt":
+			db = db.Where("? < ?", column, f.values[0])
+		case "gt":
+			db = db.Where("? > ?", column, f.values[0])
+		case "like":
+			db = db.Where("? ILIKE ?", column, "%"+likeEscaper.Replace(f.values[0].(string))+"%")
+		case "in":
+			db = db.Where("? IN ?", column, f.values)
+		case "between":
+			db = db.Where("? BETWEEN ? AND ?", column, f.values[0], f.values[1])
+		}
+	}
+
+	return db
+}
+
+// Order applies the sorts, with id as the tie-breaker.
+func (l *ListQuery) Order(db *gorm.DB) *gorm.DB {
+	if l == nil {
+		return db
+	}
+
+	for _, s := range l.sorts {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: s.Column}, Desc: s.Desc})
+	}
+
+	if len(l.sorts) > 0 {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: l.sorts[0].Desc})
+	}
+
+	return db
+}
+
+// Cursor returns the sorts the cursor pagination is keyed on: the requested
+// ones, newest first otherwise.
+func (l *ListQuery) Cursor() []Sort {
+	if l == nil || len(l.sorts) == 0 {
+		return []Sort{{Column: "created_at", Desc: true}}
+	}
+	return l.sorts
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var testResource = Resource{
+	Fields: map[string]Field{
+		"id":         {Column: "id", Key: "ID", Type: FieldInt},
+		"title":      {Column: "title", Key: "title"},
+		"created_at": {Column: "created_at", Key: "CreatedAt", Type: FieldTime},
+	},
+	Filters: []string{"id", "title", "created_at"},
+	Sorts:   []string{"id", "title", "created_at"},
+}
+
+func testContext(target string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", target, nil)
+	return c
+}
+
+func dryRun(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}), &gorm.Config{
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestParseListQueryRejects(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":         "/?filter[body]=x",
+		"like on a number":      "/?filter[id][like]=1",
+		"lt on text":            "/?filter[title][lt]=a",
+		"malformed number":      "/?filter[id]=abc",
+		"malformed number list": "/?filter[id][in]=1,x",
+		"malformed date":        "/?filter[created_at][gt]=yesterday",
+		"between one value":     "/?filter[id][between]=1",
+		"unknown sort":          "/?sort=body",
+	}
+
+	for name, target := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseListQuery(testContext(target), testResource); err == nil {
+				t.Fatal("the query was accepted")
+			}
+		})
+	}
+}
+
+func TestListQueryScope(t *testing.T) {
+	list, err := ParseListQuery(testContext("/?filter[title][like]=50%25_off&filter[id][between]=1,9&filter[created_at][gt]=2024-01-02&sort=-title"), testResource)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sql := dryRun(t).ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var rows []map[string]any
+		return list.Order(list.Scope(tx.Table("posts"))).Find(&rows)
+	})
+
+	for _, want := range []string{
+		`"created_at" > '2024-01-02 00:00:00'`,
+		`"id" BETWEEN 1 AND 9`,
+		`"title" ILIKE '%50\%\_off%'`,
+		`ORDER BY "title" DESC,"id" DESC`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("%s\ndoes not contain %s", sql, want)
+		}
+	}
+}
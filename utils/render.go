@@ -0,0 +1,298 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/ugorji/go/codec"
+	"gopkg.in/yaml.v3"
+)
+
+const MIMECSV = "text/csv"
+
+var offeredFormats = []string{
+	binding.MIMEJSON,
+	binding.MIMEXML,
+	binding.MIMEXML2,
+	binding.MIMEMSGPACK,
+	binding.MIMEMSGPACK2,
+	binding.MIMEYAML,
+	binding.MIMEYAML2,
+	MIMECSV,
+}
+
+// ShouldBind binds the request body according to its Content-Type and falls
+// back to JSON when none is given.
+func ShouldBind(c *gin.Context, obj any) error {
+	switch c.ContentType() {
+	case binding.MIMEXML, binding.MIMEXML2:
+		return c.ShouldBindWith(obj, binding.XML)
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		return c.ShouldBindWith(obj, binding.MsgPack)
+	case binding.MIMEYAML, binding.MIMEYAML2:
+		return c.ShouldBindWith(obj, binding.YAML)
+	default:
+		return c.ShouldBindWith(obj, binding.JSON)
+	}
+}
+
+// Render writes obj in the format negotiated from the Accept header. Every
+// format except JSON is encoded from the JSON representation of obj so field
+// names stay the same whatever the client asks for.
+func Render(c *gin.Context, code int, obj any) {
+	format := c.NegotiateFormat(offeredFormats...)
+	if format == "" || format == binding.MIMEJSON {
+		c.JSON(code, obj)
+		return
+	}
+
+	tree, err := toTree(obj)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": err.Error()})
+		return
+	}
+
+	var body []byte
+
+	switch format {
+	case binding.MIMEXML, binding.MIMEXML2:
+		body, err = encodeXML(tree)
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		err = codec.NewEncoderBytes(&body, new(codec.MsgpackHandle)).Encode(tree)
+	case binding.MIMEYAML, binding.MIMEYAML2:
+		body, err = yaml.Marshal(tree)
+	case MIMECSV:
+		body, err = encodeCSV(tree)
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": err.Error()})
+		return
+	}
+
+	c.Data(code, format+"; charset=utf-8", body)
+}
+
+// toTree converts obj into maps, slices and scalars using its JSON encoding.
+func toTree(obj any) (any, error) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	// numbers are decoded as written, float64 would round large integers
+	var tree any
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+
+	return normalize(tree), nil
+}
+
+// normalize turns the JSON numbers of a decoded tree into integers or
+// floats.
+func normalize(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = normalize(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+	case json.Number:
+		return jsonNumber(v)
+	}
+	return value
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func encodeXML(tree any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(&buf)
+	if err := writeXML(enc, "response", tree); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// xmlName makes key a valid XML element name, replacing the characters a
+// name cannot hold with _ and prefixing the ones a name cannot start with.
+func xmlName(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+		case unicode.IsDigit(r) || r == '-' || r == '.':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+		default:
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+
+	// names starting with xml are reserved
+	name := b.String()
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		name = "_" + name
+	}
+	return name
+}
+
+func writeXML(enc *xml.Encoder, name string, value any) error {
+	start := xml.StartElement{Name: xml.Name{Local: xmlName(name)}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, key := range sortedKeys(v) {
+			if err := writeXML(enc, key, v[key]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if err := writeXML(enc, "item", item); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if err := enc.EncodeToken(xml.CharData(scalar(v))); err != nil {
+			return err
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+// encodeCSV writes list data as one row per item, flattening nested objects
+// into dotted columns such as user.name. Paginated results are unwrapped to
+// their data, anything else becomes a single row.
+func encodeCSV(tree any) ([]byte, error) {
+	var rows []any
+
+	data := tree
+	if response, ok := tree.(map[string]any); ok && response["data"] != nil {
+		data = response["data"]
+	}
+	if page, ok := data.(map[string]any); ok {
+		if items, ok := page["data"].([]any); ok {
+			data = items
+		}
+	}
+
+	switch v := data.(type) {
+	case []any:
+		rows = v
+	case map[string]any:
+		rows = []any{v}
+	default:
+		rows = []any{tree}
+	}
+
+	flatRows := make([]map[string]string, 0, len(rows))
+	columns := map[string]any{}
+	for _, row := range rows {
+		flat := map[string]string{}
+		flatten("", row, flat)
+		for key := range flat {
+			columns[key] = nil
+		}
+		flatRows = append(flatRows, flat)
+	}
+
+	header := sortedKeys(columns)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	for _, flat := range flatRows {
+		record := make([]string, len(header))
+		for i, key := range header {
+			record[i] = flat[key]
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func flatten(prefix string, value any, out map[string]string) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flatten(key, item, out)
+		}
+	case []any:
+		for i, item := range v {
+			key := strconv.Itoa(i)
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flatten(key, item, out)
+		}
+	default:
+		if prefix == "" {
+			prefix = "value"
+		}
+		out[prefix] = csvCell(v)
+	}
+}
+
+// csvCell guards the strings a spreadsheet would run as a formula with a
+// leading quote. Numbers are safe, negative ones included.
+func csvCell(value any) string {
+	cell := scalar(value)
+	if _, ok := value.(string); ok && cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func scalar(value any) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
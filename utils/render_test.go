@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"encoding/xml"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestXMLName(t *testing.T) {
+	tests := map[string]string{
+		"title":      "title",
+		"user.name":  "user.name",
+		"body_html":  "body_html",
+		"1st":        "_1st",
+		"-x":         "_-x",
+		"a b<c>":     "a_b_c_",
+		"ns:tag":     "ns_tag",
+		"":           "_",
+		"xmlns":      "_xmlns",
+		"XMLVersion": "_XMLVersion",
+		"été":        "été",
+	}
+
+	for key, want := range tests {
+		if got := xmlName(key); got != want {
+			t.Fatalf("xmlName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestEncodeXML(t *testing.T) {
+	tree, err := toTree(map[string]any{
+		"1":     "one",
+		"a b":   []int{1, 2},
+		"nil":   nil,
+		"<tag>": map[string]string{"xml": "x"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := encodeXML(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "<response><_1>one</_1><_tag_><_xml>x</_xml></_tag_><a_b><item>1</item><item>2</item></a_b><nil></nil></response>"
+	if !strings.HasSuffix(string(body), want) {
+		t.Fatalf("got %s, want %s", body, want)
+	}
+
+	var doc any
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("the document does not parse: %s", err)
+	}
+}
+
+func TestToTreeKeepsNumbers(t *testing.T) {
+	tree, err := toTree(map[string]any{
+		"big":      int64(math.MaxInt64),
+		"unsigned": uint64(math.MaxUint64),
+		"float":    1.5,
+		"negative": -3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"big":      int64(math.MaxInt64),
+		"unsigned": uint64(math.MaxUint64),
+		"float":    1.5,
+		"negative": int64(-3),
+	}
+	if !reflect.DeepEqual(tree, want) {
+		t.Fatalf("got %#v, want %#v", tree, want)
+	}
+}
+
+func TestEncodeCSVFormulas(t *testing.T) {
+	tree, err := toTree(map[string]any{
+		"data": []map[string]any{
+			{"title": "=HYPERLINK(\"http://evil\")", "user": map[string]any{"name": "@admin"}, "delta": -3},
+			{"title": "+1", "user": map[string]any{"name": "\tbob"}, "delta": 2},
+			{"title": "plain - text", "user": map[string]any{"name": "-x"}, "delta": 0},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := encodeCSV(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "delta,title,user.name\n" +
+		"-3,\"'=HYPERLINK(\"\"http://evil\"\")\",'@admin\n" +
+		"2,'+1,'\tbob\n" +
+		"0,plain - text,'-x\n"
+	if string(body) != want {
+		t.Fatalf("got\n%s\nwant\n%s", body, want)
+	}
+}
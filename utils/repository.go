@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"gin-rest-api/config"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository reads records of type T.
+type Repository[T any] struct {
+	cfg *config.Config
+	db  *gorm.DB
+}
+
+func NewRepository[T any](cfg *config.Config, db *gorm.DB) *Repository[T] {
+	return &Repository[T]{cfg, db}
+}
+
+// First returns the record with the given primary key.
+func (r *Repository[T]) First(id any, scopes ...func(*gorm.DB) *gorm.DB) (T, error) {
+	return r.first(scopes, id)
+}
+
+// FirstBy returns the record whose column equals value.
+func (r *Repository[T]) FirstBy(column string, value any, scopes ...func(*gorm.DB) *gorm.DB) (T, error) {
+	return r.first(scopes, clause.Eq{Column: clause.Column{Name: column}, Value: value})
+}
+
+func (r *Repository[T]) first(scopes []func(*gorm.DB) *gorm.DB, conds ...any) (T, error) {
+	var record T
+
+	query := r.db
+	for _, scope := range scopes {
+		query = scope(query)
+	}
+
+	err := query.First(&record, conds...).Error
+	return record, err
+}
+
+// Paginate returns a page of records in offset or cursor mode, as requested.
+func (r *Repository[T]) Paginate(params PageParams, scopes ...func(*gorm.DB) *gorm.DB) (Page[T], error) {
+	if params.UseCursor {
+		return CursorPaginate[T](r.db, r.cfg, params, scopes...)
+	}
+
+	return Paginate[T](r.db, params, scopes...)
+}
+
+// Trashed limits a query to soft-deleted records.
+func Trashed(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Where("deleted_at IS NOT NULL")
+}
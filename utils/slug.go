@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"gin-rest-api/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RedirectSlug answers with a 301 to the current slug when slug is a previous
+// slug of a record of table, and reports whether it did.
+func RedirectSlug(c *gin.Context, db *gorm.DB, table, slug string) bool {
+	var history models.SlugHistory
+
+	err := db.Where("resource = ? AND slug = ?", table, slug).First(&history).Error
+	if err != nil {
+		return false
+	}
+
+	var current string
+
+	err = db.Table(table).Where("id = ? AND deleted_at IS NULL", history.RecordID).Pluck("slug", &current).Error
+	if err != nil || current == "" {
+		return false
+	}
+
+	location := strings.TrimSuffix(c.Request.URL.Path, slug) + current
+	if c.Request.URL.RawQuery != "" {
+		location += "?" + c.Request.URL.RawQuery
+	}
+
+	c.Redirect(http.StatusMovedPermanently, location)
+	return true
+}
@@ -15,7 +15,7 @@ func StatusOK(c *gin.Context, data any, message ...string) {
 		msg = message[0]
 	}
 
-	c.JSON(http.StatusOK, models.Response{
+	Render(c, http.StatusOK, models.Response{
 		Status:  http.StatusOK,
 		Message: msg,
 		Data:    data,
@@ -23,26 +23,50 @@ func StatusOK(c *gin.Context, data any, message ...string) {
 }
 
 func StatusConflict(c *gin.Context, message any) {
-	c.JSON(http.StatusConflict, models.Response{
+	Render(c, http.StatusConflict, models.Response{
 		Status:  http.StatusConflict,
 		Message: message,
 	})
 }
 
+func StatusForbidden(c *gin.Context, message ...string) {
+	msg := "forbidden"
+	if len(message) > 0 {
+		msg = message[0]
+	}
+
+	Render(c, http.StatusForbidden, models.Response{
+		Status:  http.StatusForbidden,
+		Message: msg,
+	})
+}
+
 func StatusUnprocessable(c *gin.Context, message any) {
-	c.JSON(http.StatusUnprocessableEntity, models.Response{
+	Render(c, http.StatusUnprocessableEntity, models.Response{
 		Status:  http.StatusUnprocessableEntity,
 		Message: message,
 	})
 }
 
+func StatusTooLarge(c *gin.Context, message ...string) {
+	msg := "the request is too large"
+	if len(message) > 0 {
+		msg = message[0]
+	}
+
+	Render(c, http.StatusRequestEntityTooLarge, models.Response{
+		Status:  http.StatusRequestEntityTooLarge,
+		Message: msg,
+	})
+}
+
 func StatusBadRequest(c *gin.Context, message ...string) {
 	msg := "bad request"
 	if len(message) > 0 {
 		msg = message[0]
 	}
 
-	c.JSON(http.StatusBadRequest, models.Response{
+	Render(c, http.StatusBadRequest, models.Response{
 		Status:  http.StatusBadRequest,
 		Message: msg,
 	})
@@ -54,7 +78,7 @@ func StatusServerError(c *gin.Context, message ...string) {
 		msg = message[0]
 	}
 
-	c.JSON(http.StatusInternalServerError, models.Response{
+	Render(c, http.StatusInternalServerError, models.Response{
 		Status:  http.StatusInternalServerError,
 		Message: msg,
 	})
@@ -67,7 +91,7 @@ func StatusNotFound(c *gin.Context, err error, message ...string) {
 	}
 
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		c.JSON(http.StatusNotFound, models.Response{
+		Render(c, http.StatusNotFound, models.Response{
 			Status:  http.StatusNotFound,
 			Message: msg,
 		})
@@ -76,3 +100,17 @@ func StatusNotFound(c *gin.Context, err error, message ...string) {
 
 	StatusServerError(c)
 }
+
+func StatusDBError(c *gin.Context, err error, message ...string) {
+	if dbErr, ok := TranslateDBError(err); ok {
+		Render(c, dbErr.Status, models.Response{
+			Status: dbErr.Status,
+			Message: map[string]any{
+				dbErr.Field: dbErr.Message,
+			},
+		})
+		return
+	}
+
+	StatusServerError(c, message...)
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	_ "image/gif"
+)
+
+// MaxImagePixels bounds the images that are decoded for thumbnails.
+const MaxImagePixels = 40_000_000
+
+var ErrImageTooLarge = errors.New("the image is too large")
+
+// Thumbnail decodes a JPEG, PNG or GIF image and writes a copy scaled down to
+// fit in size x size pixels, as a JPEG for JPEG sources and as a PNG for the
+// others. It returns the dimensions of the source image.
+func Thumbnail(w io.Writer, r io.ReadSeeker, size int) (width, height int, err error) {
+	config, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if config.Width*config.Height > MaxImagePixels {
+		return 0, 0, ErrImageTooLarge
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	dst := scaleDown(src, size)
+
+	if format == "jpeg" {
+		err = jpeg.Encode(w, dst, &jpeg.Options{Quality: 80})
+	} else {
+		err = png.Encode(w, dst)
+	}
+
+	return config.Width, config.Height, err
+}
+
+// ThumbnailType is the content type of the thumbnail of an image.
+func ThumbnailType(contentType string) string {
+	if contentType == "image/jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// scaleDown averages the source pixels that fall in every pixel of the
+// scaled image.
+func scaleDown(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	tw, th := w, h
+	if w > size || h > size {
+		if w >= h {
+			tw, th = size, max(h*size/w, 1)
+		} else {
+			tw, th = max(w*size/h, 1), size
+		}
+	}
+
+	dst := image.NewRGBA64(image.Rect(0, 0, tw, th))
+
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+max((y+1)*h/th, y*h/th+1)
+
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+max((x+1)*w/tw, x*w/tw+1)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+
+			dst.SetRGBA64(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)})
+		}
+	}
+
+	return dst
+}
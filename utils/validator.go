@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/id"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	idTranslations "github.com/go-playground/validator/v10/translations/id"
+	"golang.org/x/text/language"
+)
+
+var uni *ut.UniversalTranslator
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// messages translates, by tag and locale, the tags the translations of the
+// validator lack. {0} is the field and {1} the tag param.
+var messages = map[string]map[string]string{
+	"username": {
+		"en": "{0} may only contain lowercase letters, digits and underscores",
+		"id": "{0} hanya boleh berisi huruf kecil, angka, dan garis bawah",
+	},
+	"http_url": {
+		"en": "{0} must be a valid http or https URL",
+		"id": "{0} harus berupa URL http atau https yang valid",
+	},
+	"required_if": {
+		"id": "{0} wajib diisi",
+	},
+	"datetime": {
+		"id": "{0} tidak sesuai dengan format {1}",
+	},
+}
+
+func InitValidator() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unsupported validator engine")
+	}
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	enLocale := en.New()
+	uni = ut.New(enLocale, enLocale, id.New())
+
+	registers := map[string]func(*validator.Validate, ut.Translator) error{
+		"en": enTranslations.RegisterDefaultTranslations,
+		"id": idTranslations.RegisterDefaultTranslations,
+	}
+
+	if err := v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return usernamePattern.MatchString(fl.Field().String())
+	}); err != nil {
+		return err
+	}
+
+	for locale, register := range registers {
+		trans, _ := uni.GetTranslator(locale)
+		if err := register(v, trans); err != nil {
+			return err
+		}
+
+		for tag, byLocale := range messages {
+			message, ok := byLocale[locale]
+			if !ok {
+				continue
+			}
+
+			err := v.RegisterTranslation(tag, trans, func(t ut.Translator) error {
+				return t.Add(tag, message, true)
+			}, func(t ut.Translator, fe validator.FieldError) string {
+				msg, _ := t.T(fe.Tag(), fe.Field(), fe.Param())
+				return msg
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func GetTranslator(c *gin.Context) ut.Translator {
+	tags, _, _ := language.ParseAcceptLanguage(c.GetHeader("Accept-Language"))
+
+	locales := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		base, _ := tag.Base()
+		locales = append(locales, base.String())
+	}
+
+	trans, _ := uni.FindTranslator(locales...)
+	return trans
+}
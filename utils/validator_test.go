@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"errors"
+	"gin-rest-api/models"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func TestValidatorMessages(t *testing.T) {
+	if err := InitValidator(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		obj    any
+		field  string
+		locale string
+		want   string
+	}{
+		{"username", &models.ProfileRequest{Username: "Not Valid"}, "username", "en",
+			"username may only contain lowercase letters, digits and underscores"},
+		{"http_url en", &models.ProfileRequest{Username: "bob", Website: "ftp://example.com"}, "website", "en",
+			"website must be a valid http or https URL"},
+		{"http_url id", &models.ProfileRequest{Username: "bob", Website: "example"}, "website", "id",
+			"website harus berupa URL http atau https yang valid"},
+		{"required_if en", &models.PostStatusRequest{Status: "scheduled"}, "publishAt", "en",
+			"publishAt is a required field"},
+		{"required_if id", &models.PostStatusRequest{Status: "scheduled"}, "publishAt", "id",
+			"publishAt wajib diisi"},
+		{"datetime en", &models.PostViewsRequest{From: "01/02/2024"}, "from", "en",
+			"from does not match the 2006-01-02 format"},
+		{"datetime id", &models.PostViewsRequest{From: "01/02/2024"}, "from", "id",
+			"from tidak sesuai dengan format 2006-01-02"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := testContext("/")
+			c.Request.Header.Set("Accept-Language", test.locale)
+
+			var errs validator.ValidationErrors
+			if err := binding.Validator.ValidateStruct(test.obj); !errors.As(err, &errs) {
+				t.Fatalf("got %v, want validation errors", err)
+			}
+
+			if got := FormatErrors(c, errs)[test.field]; got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"gin-rest-api/config"
+	"gin-rest-api/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	viewsPendingKey  = "views_pending"
+	viewsFlushingKey = "views_flushing"
+	viewsLockKey     = "views_flush_lock"
+)
+
+// unlockScript releases a lock only if it is still held by the same owner,
+// not after it expired and was taken by someone else.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// Views buffers the views of posts in Redis so that reading a post does not
+// write to the database. A viewer is counted once per post and window,
+// tracked with a HyperLogLog, and the counts pile up in a hash per post and
+// day until they are flushed.
+type Views struct {
+	cfg *config.Config
+	rds *redis.Client
+}
+
+func NewViews(cfg *config.Config, rds *redis.Client) *Views {
+	return &Views{cfg, rds}
+}
+
+// Count records a view of the post by viewer, unless the viewer already
+// viewed it in the current window. HyperLogLogs are approximate, so a few
+// first views may go uncounted on busy posts.
+func (v *Views) Count(ctx context.Context, postId uint, viewer string) error {
+	now := time.Now().UTC()
+	window := time.Duration(v.cfg.ViewWindow) * time.Second
+	seenKey := "views_seen_" + strconv.Itoa(int(postId)) + "_" + strconv.FormatInt(now.Truncate(window).Unix(), 10)
+
+	var added *redis.IntCmd
+
+	_, err := v.rds.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		added = pipe.PFAdd(ctx, seenKey, viewer)
+		pipe.Expire(ctx, seenKey, window)
+		return nil
+	})
+	if err != nil || added.Val() == 0 {
+		return err
+	}
+
+	return v.rds.HIncrBy(ctx, viewsPendingKey, strconv.Itoa(int(postId))+"_"+now.Format(time.DateOnly), 1).Err()
+}
+
+// Lock keeps other instances from flushing the views at the same time,
+// which would store the same batch twice. It reports false when another one
+// holds the lock. The lock expires after ttl in case its holder dies.
+func (v *Views) Lock(ctx context.Context, ttl time.Duration) (unlock func() error, ok bool, err error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, false, err
+	}
+	owner := hex.EncodeToString(token)
+
+	ok, err = v.rds.SetNX(ctx, viewsLockKey, owner, ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	return func() error {
+		return unlockScript.Run(ctx, v.rds, []string{viewsLockKey}, owner).Err()
+	}, true, nil
+}
+
+// Pending sets the buffered counts aside and returns them. They are returned
+// again until Ack, so counts are not lost when storing them fails, and views
+// keep being buffered in the meantime.
+func (v *Views) Pending(ctx context.Context) ([]models.PostDailyView, error) {
+	// a batch that was not acknowledged is stored again before a new one
+	pending, err := v.rds.Exists(ctx, viewsPendingKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if pending > 0 {
+		if err := v.rds.RenameNX(ctx, viewsPendingKey, viewsFlushingKey).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	fields, err := v.rds.HGetAll(ctx, viewsFlushingKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]models.PostDailyView, 0, len(fields))
+	for field, value := range fields {
+		id, day, _ := strings.Cut(field, "_")
+
+		postId, err := strconv.ParseUint(id, 10, 0)
+		if err != nil {
+			continue
+		}
+
+		date, err := time.Parse(time.DateOnly, day)
+		if err != nil {
+			continue
+		}
+
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		views = append(views, models.PostDailyView{PostID: uint(postId), Date: date, Views: count})
+	}
+
+	return views, nil
+}
+
+// Ack drops the counts returned by Pending once they are stored.
+func (v *Views) Ack(ctx context.Context) error {
+	return v.rds.Del(ctx, viewsFlushingKey).Err()
+}
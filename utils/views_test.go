@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"context"
+	"gin-rest-api/config"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func testRedis(t *testing.T) (*redis.Client, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	rds := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { rds.Close() })
+
+	return rds, server
+}
+
+func testViews(t *testing.T) (*Views, *miniredis.Miniredis) {
+	rds, server := testRedis(t)
+	return NewViews(&config.Config{ViewWindow: 1800}, rds), server
+}
+
+func TestViewsPending(t *testing.T) {
+	views, _ := testViews(t)
+	ctx := context.Background()
+
+	pending, err := views.Pending(ctx)
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("got %v, %v without views", pending, err)
+	}
+
+	for _, viewer := range []string{"a", "b", "a"} {
+		if err := views.Count(ctx, 7, viewer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pending, err = views.Pending(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].PostID != 7 || pending[0].Views != 2 {
+		t.Fatalf("got %+v, want 2 views of post 7", pending)
+	}
+
+	// views counted before the ack wait for the next batch
+	if err := views.Count(ctx, 8, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := views.Pending(ctx)
+	if err != nil || len(again) != 1 || again[0].PostID != 7 {
+		t.Fatalf("got %+v, %v, want the unacknowledged batch again", again, err)
+	}
+
+	if err := views.Ack(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := views.Pending(ctx)
+	if err != nil || len(next) != 1 || next[0].PostID != 8 || next[0].Views != 1 {
+		t.Fatalf("got %+v, %v, want 1 view of post 8", next, err)
+	}
+}
+
+func TestViewsLock(t *testing.T) {
+	views, server := testViews(t)
+	ctx := context.Background()
+
+	unlock, ok, err := views.Lock(ctx, time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("got %v, %v, want the lock", ok, err)
+	}
+
+	if _, ok, err := views.Lock(ctx, time.Minute); err != nil || ok {
+		t.Fatalf("got %v, %v, want the lock to be held", ok, err)
+	}
+
+	// an expired lock taken over is not released by its former owner
+	server.FastForward(time.Minute)
+	unlockOther, ok, err := views.Lock(ctx, time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("got %v, %v, want the expired lock", ok, err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := views.Lock(ctx, time.Minute); ok {
+		t.Fatal("the lock was released by its former owner")
+	}
+
+	if err := unlockOther(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := views.Lock(ctx, time.Minute); err != nil || !ok {
+		t.Fatalf("got %v, %v, want the released lock", ok, err)
+	}
+}